@@ -3,6 +3,8 @@ package ast
 import (
 	"github.com/alanfoster/monkey/token"
 	"bytes"
+	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -10,6 +12,10 @@ type Node interface {
 	// Temporary function only used for debugging and testing
 	TokenLiteral() string
 	PrettyPrint() string
+	// The position of the first character belonging to the node
+	Pos() token.Position
+	// The position immediately after the last character belonging to the node
+	End() token.Position
 }
 
 type Statement interface {
@@ -34,6 +40,19 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -55,6 +74,16 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
+
 func (ls *LetStatement) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -80,6 +109,12 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
 func (i *Identifier) PrettyPrint() string {
 	return i.Value
 }
@@ -94,6 +129,16 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+func (rs *ReturnStatement) End() token.Position {
+	if rs.Value != nil {
+		return rs.Value.End()
+	}
+	return rs.Token.End
+}
+
 func (rs *ReturnStatement) PrettyPrint() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
@@ -116,6 +161,16 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
+
 func (es *ExpressionStatement) PrettyPrint() string {
 	if es.Expression != nil {
 		return es.Expression.PrettyPrint()
@@ -133,10 +188,60 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
 func (il *IntegerLiteral) PrettyPrint() string {
 	return il.Token.Literal
 }
 
+// An integer of arbitrary precision, written with an n suffix or too large for an IntegerLiteral
+type BigIntLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntLiteral) expressionNode() {}
+func (bl *BigIntLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+
+func (bl *BigIntLiteral) Pos() token.Position {
+	return bl.Token.Pos
+}
+func (bl *BigIntLiteral) End() token.Position {
+	return bl.Token.End
+}
+
+func (bl *BigIntLiteral) PrettyPrint() string {
+	return bl.Token.Literal
+}
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
+func (fl *FloatLiteral) PrettyPrint() string {
+	return fl.Token.Literal
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -146,6 +251,12 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
 func (sl *StringLiteral) PrettyPrint() string {
 	return sl.Value
 }
@@ -153,12 +264,19 @@ func (sl *StringLiteral) PrettyPrint() string {
 type ArrayLiteral struct {
 	Token    token.Token // The [ token
 	Elements []Expression
+	EndToken token.Token // The ] token
 }
 
 func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+func (al *ArrayLiteral) End() token.Position {
+	return al.EndToken.End
+}
 func (al *ArrayLiteral) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -174,6 +292,43 @@ func (al *ArrayLiteral) PrettyPrint() string {
 	return out.String()
 }
 
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// AST For `{key: value, ...}`. The pairs are kept in source order
+type HashLiteral struct {
+	Token    token.Token // The { token
+	Pairs    []*HashPair
+	EndToken token.Token // The } token
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+func (hl *HashLiteral) End() token.Position {
+	return hl.EndToken.End
+}
+func (hl *HashLiteral) PrettyPrint() string {
+	var out bytes.Buffer
+
+	var pairs []string
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.PrettyPrint()+": "+pair.Value.PrettyPrint())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -185,6 +340,16 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
+
 func (pe *PrefixExpression) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -209,6 +374,16 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
+
 func (ie *InfixExpression) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -221,6 +396,41 @@ func (ie *InfixExpression) PrettyPrint() string {
 	return out.String()
 }
 
+// AST For `target = value`, as well as compound assignments such as `target += value`. The target
+// is either an Identifier or an IndexExpression
+type AssignExpression struct {
+	Token    token.Token // The assignment operator token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Target.Pos()
+}
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+
+func (ae *AssignExpression) PrettyPrint() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.PrettyPrint())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.PrettyPrint())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -230,6 +440,12 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+func (b *Boolean) End() token.Position {
+	return b.Token.End
+}
 func (b *Boolean) PrettyPrint() string {
 	return b.Token.Literal
 }
@@ -245,6 +461,15 @@ func (b *IfExpression) expressionNode() {}
 func (b *IfExpression) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *IfExpression) Pos() token.Position {
+	return b.Token.Pos
+}
+func (b *IfExpression) End() token.Position {
+	if b.FalseBlock != nil {
+		return b.FalseBlock.End()
+	}
+	return b.TrueBlock.End()
+}
 func (b *IfExpression) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -264,14 +489,21 @@ func (b *IfExpression) PrettyPrint() string {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // The { token
 	Statements []Statement
+	EndToken   token.Token // The } token
 }
 
 func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BlockStatement) End() token.Position {
+	return bs.EndToken.End
+}
 func (bs *BlockStatement) PrettyPrint() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -281,6 +513,181 @@ func (bs *BlockStatement) PrettyPrint() string {
 	return out.String()
 }
 
+// AST For `while (condition) { body }`
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+func (ws *WhileStatement) End() token.Position {
+	return ws.Body.End()
+}
+func (ws *WhileStatement) PrettyPrint() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(ws.Condition.PrettyPrint())
+	out.WriteString(") {")
+	out.WriteString(ws.Body.PrettyPrint())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// AST For `for (variable in iterable) { body }`
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+func (fs *ForStatement) End() token.Position {
+	return fs.Body.End()
+}
+func (fs *ForStatement) PrettyPrint() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.PrettyPrint())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.PrettyPrint())
+	out.WriteString(") {")
+	out.WriteString(fs.Body.PrettyPrint())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BreakStatement) End() token.Position {
+	return bs.Token.End
+}
+func (bs *BreakStatement) PrettyPrint() string {
+	return bs.TokenLiteral()
+}
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+func (cs *ContinueStatement) End() token.Position {
+	return cs.Token.End
+}
+func (cs *ContinueStatement) PrettyPrint() string {
+	return cs.TokenLiteral()
+}
+
+// AST For `throw value`
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) Pos() token.Position {
+	return ts.Token.Pos
+}
+func (ts *ThrowStatement) End() token.Position {
+	if ts.Value != nil {
+		return ts.Value.End()
+	}
+	return ts.Token.End
+}
+func (ts *ThrowStatement) PrettyPrint() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.PrettyPrint())
+	}
+
+	out.WriteString(";")
+	return out.String()
+}
+
+// AST For `try { body } catch (parameter) { catch } finally { finally }`, where either the catch or
+// the finally block may be left out
+type TryExpression struct {
+	Token          token.Token
+	Body           *BlockStatement
+	CatchParameter *Identifier
+	CatchBlock     *BlockStatement
+	FinallyBlock   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+func (te *TryExpression) End() token.Position {
+	if te.FinallyBlock != nil {
+		return te.FinallyBlock.End()
+	}
+	return te.CatchBlock.End()
+}
+func (te *TryExpression) PrettyPrint() string {
+	var out bytes.Buffer
+
+	out.WriteString("try {")
+	out.WriteString(te.Body.PrettyPrint())
+	out.WriteString("}")
+
+	if te.CatchBlock != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.CatchParameter.PrettyPrint())
+		out.WriteString(") {")
+		out.WriteString(te.CatchBlock.PrettyPrint())
+		out.WriteString("}")
+	}
+
+	if te.FinallyBlock != nil {
+		out.WriteString(" finally {")
+		out.WriteString(te.FinallyBlock.PrettyPrint())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
@@ -291,6 +698,12 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FunctionLiteral) End() token.Position {
+	return fl.Body.End()
+}
 func (fl *FunctionLiteral) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -309,16 +722,58 @@ func (fl *FunctionLiteral) PrettyPrint() string {
 	return out.String()
 }
 
+// AST For `macro(x, y) { ... }`, which receives its arguments unevaluated as quoted AST nodes
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+func (ml *MacroLiteral) Pos() token.Position {
+	return ml.Token.Pos
+}
+func (ml *MacroLiteral) End() token.Position {
+	return ml.Body.End()
+}
+func (ml *MacroLiteral) PrettyPrint() string {
+	var out bytes.Buffer
+
+	parameters := []string{}
+	for _, identifier := range ml.Parameters {
+		parameters = append(parameters, identifier.PrettyPrint())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(parameters, ", "))
+	out.WriteString(") { ")
+	out.WriteString(ml.Body.PrettyPrint())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
 type CallExpression struct {
-	Token     token.Token
-	Function  Expression // Identifier or function literal
+	Token     token.Token // The ( token
+	Function  Expression  // Identifier or function literal
 	Arguments []Expression
+	EndToken  token.Token // The ) token
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Function.Pos()
+}
+func (ce *CallExpression) End() token.Position {
+	return ce.EndToken.End
+}
 func (ce *CallExpression) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -335,16 +790,64 @@ func (ce *CallExpression) PrettyPrint() string {
 	return out.String()
 }
 
+// AST For `import "path/to/lib.monkey"`, which evaluates to the module loaded from that file
+type ImportExpression struct {
+	Token token.Token // The import token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode() {}
+func (ie *ImportExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *ImportExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+func (ie *ImportExpression) End() token.Position {
+	return ie.Path.End()
+}
+func (ie *ImportExpression) PrettyPrint() string {
+	return fmt.Sprintf("import %q", ie.Path.Value)
+}
+
+// AST For `object.property`, such as accessing the export of a module with `lib.name`
+type MemberExpression struct {
+	Token    token.Token // The . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MemberExpression) Pos() token.Position {
+	return me.Object.Pos()
+}
+func (me *MemberExpression) End() token.Position {
+	return me.Property.End()
+}
+func (me *MemberExpression) PrettyPrint() string {
+	return "(" + me.Object.PrettyPrint() + "." + me.Property.PrettyPrint() + ")"
+}
+
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ token
+	Left     Expression
+	Index    Expression
+	EndToken token.Token // The ] token
 }
 
 func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
+func (ie *IndexExpression) End() token.Position {
+	return ie.EndToken.End
+}
 func (ie *IndexExpression) PrettyPrint() string {
 	var out bytes.Buffer
 
@@ -356,3 +859,43 @@ func (ie *IndexExpression) PrettyPrint() string {
 
 	return out.String()
 }
+
+// A placeholder for an expression containing a syntax error, spanning the skipped tokens
+type BadExpression struct {
+	Token    token.Token // The first token of the invalid expression
+	EndToken token.Token // The last token of the invalid expression
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string {
+	return be.Token.Literal
+}
+func (be *BadExpression) Pos() token.Position {
+	return be.Token.Pos
+}
+func (be *BadExpression) End() token.Position {
+	return be.EndToken.End
+}
+func (be *BadExpression) PrettyPrint() string {
+	return "<bad expression>"
+}
+
+// A placeholder for a statement containing a syntax error, spanning the skipped tokens
+type BadStatement struct {
+	Token    token.Token // The first token of the invalid statement
+	EndToken token.Token // The last token of the invalid statement
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BadStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BadStatement) End() token.Position {
+	return bs.EndToken.End
+}
+func (bs *BadStatement) PrettyPrint() string {
+	return "<bad statement>"
+}
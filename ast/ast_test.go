@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"fmt"
 	"testing"
 	"github.com/alanfoster/monkey/token"
 	"github.com/stretchr/testify/assert"
@@ -35,3 +36,215 @@ func TestPrettyPrint(t *testing.T) {
 	expectedString := "let myVar = anotherVar;"
 	assert.Equal(t, expectedString, program.PrettyPrint())
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Predicate:  one(),
+				TrueBlock:  &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				FalseBlock: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Predicate:  two(),
+				TrueBlock:  &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				FalseBlock: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{Value: one()}, &ReturnStatement{Value: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{
+			&HashLiteral{Pairs: []*HashPair{{Key: one(), Value: one()}}},
+			&HashLiteral{Pairs: []*HashPair{{Key: two(), Value: two()}}},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two()}},
+		},
+		{
+			&AssignExpression{Target: &IndexExpression{Left: one(), Index: one()}, Operator: "=", Value: one()},
+			&AssignExpression{Target: &IndexExpression{Left: two(), Index: two()}, Operator: "=", Value: two()},
+		},
+		{
+			&WhileStatement{Condition: one(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&WhileStatement{Condition: two(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&ForStatement{Iterable: &ArrayLiteral{Elements: []Expression{one()}}, Body: &BlockStatement{Statements: []Statement{}}},
+			&ForStatement{Iterable: &ArrayLiteral{Elements: []Expression{two()}}, Body: &BlockStatement{Statements: []Statement{}}},
+		},
+		{
+			&MacroLiteral{Body: &BlockStatement{Statements: []Statement{&ReturnStatement{Value: one()}}}},
+			&MacroLiteral{Body: &BlockStatement{Statements: []Statement{&ReturnStatement{Value: two()}}}},
+		},
+		{
+			&MemberExpression{Object: one(), Property: &Identifier{Value: "name"}},
+			&MemberExpression{Object: two(), Property: &Identifier{Value: "name"}},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&TryExpression{
+				Body:           &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				CatchParameter: &Identifier{Value: "e"},
+				CatchBlock:     &BlockStatement{Statements: []Statement{&ThrowStatement{Value: one()}}},
+				FinallyBlock:   &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&TryExpression{
+				Body:           &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				CatchParameter: &Identifier{Value: "e"},
+				CatchBlock:     &BlockStatement{Statements: []Statement{&ThrowStatement{Value: two()}}},
+				FinallyBlock:   &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		modified := Modify(test.input, turnOneIntoTwo)
+		assert.Equal(t, test.expected, modified)
+	}
+
+	// The original tree is left untouched
+	original := &InfixExpression{Left: one(), Operator: "+", Right: one()}
+	Modify(original, turnOneIntoTwo)
+	assert.Equal(t, &InfixExpression{Left: one(), Operator: "+", Right: one()}, original)
+
+	// Identifiers which name a binding can only be replaced with other identifiers
+	rename := func(node Node) Node {
+		if identifier, ok := node.(*Identifier); ok && identifier.Value == "x" {
+			return &Identifier{Value: "y"}
+		}
+		if _, ok := node.(*IntegerLiteral); ok {
+			return &ExpressionStatement{}
+		}
+		return node
+	}
+	let := &LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: &Identifier{Value: "x"}, Value: &InfixExpression{Left: &Identifier{Value: "x"}, Operator: "+", Right: one()}}
+	assert.Equal(t, "let y = (y + 1);", Modify(let, rename).PrettyPrint())
+}
+
+func TestInspect(t *testing.T) {
+	identifier := func(name string) *Identifier { return &Identifier{Value: name} }
+
+	// let add = fn(a, b) { return a + b; }; for (x in [add(1, 2)]) { x }
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: identifier("add"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{identifier("a"), identifier("b")},
+					Body: &BlockStatement{Statements: []Statement{
+						&ReturnStatement{Value: &InfixExpression{Left: identifier("a"), Operator: "+", Right: identifier("b")}},
+					}},
+				},
+			},
+			&ForStatement{
+				Variable: identifier("x"),
+				Iterable: &ArrayLiteral{Elements: []Expression{
+					&CallExpression{Function: identifier("add"), Arguments: []Expression{
+						&IntegerLiteral{Value: 1},
+						&IntegerLiteral{Value: 2},
+					}},
+				}},
+				Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: identifier("x")}}},
+			},
+		},
+	}
+
+	var identifiers []string
+	var calls int
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			identifiers = append(identifiers, node.Value)
+		case *CallExpression:
+			calls++
+		}
+		return true
+	})
+	assert.Equal(t, []string{"add", "a", "b", "a", "b", "x", "add", "x"}, identifiers)
+	assert.Equal(t, 1, calls)
+
+	// Returning false skips the children of a node
+	var visited []string
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			return false
+		}
+		visited = append(visited, fmt.Sprintf("%T", node))
+		_, isFunction := node.(*FunctionLiteral)
+		_, isFor := node.(*ForStatement)
+		return !isFunction && !isFor
+	})
+	assert.Equal(t, []string{"*ast.Program", "*ast.LetStatement", "*ast.Identifier", "*ast.FunctionLiteral", "*ast.ForStatement"}, visited)
+}
+
+type depthCounter struct {
+	depth    int
+	maxDepth *int
+}
+
+func (d depthCounter) Visit(node Node) Visitor {
+	if node == nil {
+		return nil
+	}
+	if d.depth > *d.maxDepth {
+		*d.maxDepth = d.depth
+	}
+	return depthCounter{depth: d.depth + 1, maxDepth: d.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	// -(1 + (2 * 3))
+	expression := &PrefixExpression{
+		Operator: "-",
+		Right: &InfixExpression{
+			Left:     &IntegerLiteral{Value: 1},
+			Operator: "+",
+			Right:    &InfixExpression{Left: &IntegerLiteral{Value: 2}, Operator: "*", Right: &IntegerLiteral{Value: 3}},
+		},
+	}
+
+	maxDepth := 0
+	Walk(depthCounter{maxDepth: &maxDepth}, expression)
+	assert.Equal(t, 3, maxDepth)
+}
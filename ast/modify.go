@@ -0,0 +1,203 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Rebuilds the given node by applying the modifier to each of its children, and then to the node
+// itself. The modifier's return value replaces the node within the new tree. Nodes are copied
+// rather than updated in place, so the original tree can still be used afterwards.
+//
+// Where the modifier returns a node which is not allowed in that position, such as an expression
+// in place of a statement or the name of a let statement, the original node is kept
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		program := *node
+		program.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&program)
+	case *ExpressionStatement:
+		statement := *node
+		statement.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&statement)
+	case *InfixExpression:
+		expression := *node
+		expression.Left = modifyExpression(node.Left, modifier)
+		expression.Right = modifyExpression(node.Right, modifier)
+		return modifier(&expression)
+	case *PrefixExpression:
+		expression := *node
+		expression.Right = modifyExpression(node.Right, modifier)
+		return modifier(&expression)
+	case *IndexExpression:
+		expression := *node
+		expression.Left = modifyExpression(node.Left, modifier)
+		expression.Index = modifyExpression(node.Index, modifier)
+		return modifier(&expression)
+	case *IfExpression:
+		expression := *node
+		expression.Predicate = modifyExpression(node.Predicate, modifier)
+		expression.TrueBlock = modifyBlock(node.TrueBlock, modifier)
+		expression.FalseBlock = modifyBlock(node.FalseBlock, modifier)
+		return modifier(&expression)
+	case *BlockStatement:
+		block := *node
+		block.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&block)
+	case *ReturnStatement:
+		statement := *node
+		statement.Value = modifyExpression(node.Value, modifier)
+		return modifier(&statement)
+	case *LetStatement:
+		statement := *node
+		statement.Name = modifyIdentifier(node.Name, modifier)
+		statement.Value = modifyExpression(node.Value, modifier)
+		return modifier(&statement)
+	case *FunctionLiteral:
+		function := *node
+		function.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		function.Body = modifyBlock(node.Body, modifier)
+		return modifier(&function)
+	case *ArrayLiteral:
+		array := *node
+		array.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&array)
+	case *HashLiteral:
+		hash := *node
+		hash.Pairs = make([]*HashPair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			hash.Pairs[i] = &HashPair{
+				Key:   modifyExpression(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+		return modifier(&hash)
+	case *CallExpression:
+		call := *node
+		call.Function = modifyExpression(node.Function, modifier)
+		call.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&call)
+	case *ThrowStatement:
+		statement := *node
+		statement.Value = modifyExpression(node.Value, modifier)
+		return modifier(&statement)
+	case *AssignExpression:
+		expression := *node
+		expression.Target = modifyExpression(node.Target, modifier)
+		expression.Value = modifyExpression(node.Value, modifier)
+		return modifier(&expression)
+	case *WhileStatement:
+		statement := *node
+		statement.Condition = modifyExpression(node.Condition, modifier)
+		statement.Body = modifyBlock(node.Body, modifier)
+		return modifier(&statement)
+	case *ForStatement:
+		statement := *node
+		statement.Variable = modifyIdentifier(node.Variable, modifier)
+		statement.Iterable = modifyExpression(node.Iterable, modifier)
+		statement.Body = modifyBlock(node.Body, modifier)
+		return modifier(&statement)
+	case *TryExpression:
+		expression := *node
+		expression.Body = modifyBlock(node.Body, modifier)
+		expression.CatchParameter = modifyIdentifier(node.CatchParameter, modifier)
+		expression.CatchBlock = modifyBlock(node.CatchBlock, modifier)
+		expression.FinallyBlock = modifyBlock(node.FinallyBlock, modifier)
+		return modifier(&expression)
+	case *MacroLiteral:
+		macro := *node
+		macro.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		macro.Body = modifyBlock(node.Body, modifier)
+		return modifier(&macro)
+	case *ImportExpression:
+		expression := *node
+		if node.Path != nil {
+			if path, ok := Modify(node.Path, modifier).(*StringLiteral); ok {
+				expression.Path = path
+			}
+		}
+		return modifier(&expression)
+	case *MemberExpression:
+		expression := *node
+		expression.Object = modifyExpression(node.Object, modifier)
+		expression.Property = modifyIdentifier(node.Property, modifier)
+		return modifier(&expression)
+	}
+
+	// The remaining nodes have no children
+	return modifier(node)
+}
+
+func modifyExpression(expression Expression, modifier ModifierFunc) Expression {
+	if expression == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(expression, modifier).(Expression); ok {
+		return modified
+	}
+	return expression
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) []Expression {
+	if expressions == nil {
+		return nil
+	}
+
+	modified := make([]Expression, len(expressions))
+	for i, expression := range expressions {
+		modified[i] = modifyExpression(expression, modifier)
+	}
+	return modified
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	if statements == nil {
+		return nil
+	}
+
+	modified := make([]Statement, len(statements))
+	for i, statement := range statements {
+		modified[i] = statement
+		if statement == nil {
+			continue
+		}
+
+		if modifiedStatement, ok := Modify(statement, modifier).(Statement); ok {
+			modified[i] = modifiedStatement
+		}
+	}
+	return modified
+}
+
+func modifyIdentifier(identifier *Identifier, modifier ModifierFunc) *Identifier {
+	if identifier == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(identifier, modifier).(*Identifier); ok {
+		return modified
+	}
+	return identifier
+}
+
+func modifyIdentifiers(identifiers []*Identifier, modifier ModifierFunc) []*Identifier {
+	if identifiers == nil {
+		return nil
+	}
+
+	modified := make([]*Identifier, len(identifiers))
+	for i, identifier := range identifiers {
+		modified[i] = modifyIdentifier(identifier, modifier)
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
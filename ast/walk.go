@@ -0,0 +1,134 @@
+package ast
+
+// The Visit method is called for each node encountered by Walk. If the returned visitor is not nil,
+// Walk visits each of the children of the node with it, followed by a call of Visit(nil)
+type Visitor interface {
+	Visit(node Node) Visitor
+}
+
+// Traverses the tree in depth-first order, starting with the given node
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(v, node.Statements)
+	case *LetStatement:
+		walkIdentifier(v, node.Name)
+		walkExpression(v, node.Value)
+	case *ReturnStatement:
+		walkExpression(v, node.Value)
+	case *ThrowStatement:
+		walkExpression(v, node.Value)
+	case *ExpressionStatement:
+		walkExpression(v, node.Expression)
+	case *ArrayLiteral:
+		walkExpressions(v, node.Elements)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	case *PrefixExpression:
+		walkExpression(v, node.Right)
+	case *InfixExpression:
+		walkExpression(v, node.Left)
+		walkExpression(v, node.Right)
+	case *AssignExpression:
+		walkExpression(v, node.Target)
+		walkExpression(v, node.Value)
+	case *IfExpression:
+		walkExpression(v, node.Predicate)
+		walkBlock(v, node.TrueBlock)
+		walkBlock(v, node.FalseBlock)
+	case *BlockStatement:
+		walkStatements(v, node.Statements)
+	case *WhileStatement:
+		walkExpression(v, node.Condition)
+		walkBlock(v, node.Body)
+	case *ForStatement:
+		walkIdentifier(v, node.Variable)
+		walkExpression(v, node.Iterable)
+		walkBlock(v, node.Body)
+	case *TryExpression:
+		walkBlock(v, node.Body)
+		walkIdentifier(v, node.CatchParameter)
+		walkBlock(v, node.CatchBlock)
+		walkBlock(v, node.FinallyBlock)
+	case *FunctionLiteral:
+		for _, parameter := range node.Parameters {
+			walkIdentifier(v, parameter)
+		}
+		walkBlock(v, node.Body)
+	case *MacroLiteral:
+		for _, parameter := range node.Parameters {
+			walkIdentifier(v, parameter)
+		}
+		walkBlock(v, node.Body)
+	case *CallExpression:
+		walkExpression(v, node.Function)
+		walkExpressions(v, node.Arguments)
+	case *ImportExpression:
+		if node.Path != nil {
+			Walk(v, node.Path)
+		}
+	case *MemberExpression:
+		walkExpression(v, node.Object)
+		walkIdentifier(v, node.Property)
+	case *IndexExpression:
+		walkExpression(v, node.Left)
+		walkExpression(v, node.Index)
+	}
+
+	v.Visit(nil)
+}
+
+// Children which were not parsed, such as the value of an invalid let statement, are skipped
+func walkExpression(v Visitor, expression Expression) {
+	if expression != nil {
+		Walk(v, expression)
+	}
+}
+
+func walkExpressions(v Visitor, expressions []Expression) {
+	for _, expression := range expressions {
+		walkExpression(v, expression)
+	}
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(v, statement)
+		}
+	}
+}
+
+func walkIdentifier(v Visitor, identifier *Identifier) {
+	if identifier != nil {
+		Walk(v, identifier)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Traverses the tree in depth-first order, calling f for each node. If f returns true, Inspect
+// continues with the children of the node, followed by a call of f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
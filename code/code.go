@@ -0,0 +1,326 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/alanfoster/monkey/token"
+	"sort"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		definition, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(definition, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.formatInstruction(definition, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) formatInstruction(definition *Definition, operands []int) string {
+	operandCount := len(definition.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return definition.Name
+	case 1:
+		return fmt.Sprintf("%s %d", definition.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", definition.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", definition.Name)
+}
+
+// The source position of the instructions from Offset onwards, up until the next SourcePosition
+type SourcePosition struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Maps the instructions back to the source they were compiled from, ordered by offset
+type SourceMap []SourcePosition
+
+// Returns the source position of the instruction at the offset, invalid if it is not known
+func (sm SourceMap) Lookup(offset int) token.Position {
+	index := sort.Search(len(sm), func(i int) bool {
+		return sm[i].Offset > offset
+	})
+	if index == 0 {
+		return token.Position{}
+	}
+
+	return sm[index-1].Pos
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	// Infix operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterThanEqual
+	OpLessThanEqual
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+
+	// Prefix operators
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJumpNotTruthy
+	OpJump
+
+	OpIterStart
+	OpIterNext
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	OpCurrentClosure
+	OpAssignGlobal
+
+	OpMakeCell
+	OpGetLocalCell
+	OpSetLocalCell
+	OpGetFreeCell
+	OpSetFreeCell
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+	OpDup2
+
+	OpClosure
+	OpCall
+	OpTailCall
+	OpReturnValue
+
+	OpImport
+	OpQuote
+
+	OpTry
+	OpEndTry
+	OpThrow
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int // The number of bytes each operand takes up
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:              {"OpAdd", []int{}},
+	OpSub:              {"OpSub", []int{}},
+	OpMul:              {"OpMul", []int{}},
+	OpDiv:              {"OpDiv", []int{}},
+	OpEqual:            {"OpEqual", []int{}},
+	OpNotEqual:         {"OpNotEqual", []int{}},
+	OpGreaterThan:      {"OpGreaterThan", []int{}},
+	OpLessThan:         {"OpLessThan", []int{}},
+	OpGreaterThanEqual: {"OpGreaterThanEqual", []int{}},
+	OpLessThanEqual:    {"OpLessThanEqual", []int{}},
+	OpMod:              {"OpMod", []int{}},
+	OpPow:              {"OpPow", []int{}},
+	OpBitAnd:           {"OpBitAnd", []int{}},
+	OpBitOr:            {"OpBitOr", []int{}},
+	OpBitXor:           {"OpBitXor", []int{}},
+	OpShiftLeft:        {"OpShiftLeft", []int{}},
+	OpShiftRight:       {"OpShiftRight", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	// The operand is the absolute offset of the instruction to jump to
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	// Iterating leaves the array and the next index on the stack. The operand of OpIterNext is the
+	// offset to jump to once there are no elements left
+	OpIterStart: {"OpIterStart", []int{}},
+	OpIterNext:  {"OpIterNext", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	// Unlike OpSetGlobal, which defines a binding, this updates an existing binding
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+
+	// Cells hold the locals which closures have captured. OpMakeCell wraps the current value of the
+	// local slot in a new cell, after which the slot is accessed through the cell instructions
+	OpMakeCell:     {"OpMakeCell", []int{1}},
+	OpGetLocalCell: {"OpGetLocalCell", []int{1}},
+	OpSetLocalCell: {"OpSetLocalCell", []int{1}},
+	OpGetFreeCell:  {"OpGetFreeCell", []int{1}},
+	OpSetFreeCell:  {"OpSetFreeCell", []int{1}},
+
+	// The operand is the number of elements on the stack, for hashes this is keys plus values
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	// Pops the value, index and array or hash, pushing the assigned value
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// Duplicates the top two elements of the stack, used by compound index assignments
+	OpDup2: {"OpDup2", []int{}},
+
+	// The operands are the constant index of the function, and the number of free variables
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpCall:        {"OpCall", []int{1}},
+	// Calls in tail position replace the current frame when calling a closure, so that recursion in
+	// tail position runs in constant stack space as with the evaluator. Other calls are made as usual
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+
+	// The operand is the constant index of the resolved path of the module
+	OpImport: {"OpImport", []int{2}},
+	// The operands are the constant index of the quoted node, and the number of values popped from
+	// the stack which replace its calls to unquote
+	OpQuote: {"OpQuote", []int{2, 1}},
+
+	// Try blocks are active from OpTry until OpEndTry. The operand of OpTry is the offset of the
+	// handler, which an error raised while the block is active jumps to with the caught exception
+	// pushed on the stack. OpThrow pops the value to throw
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	OpThrow:  {"OpThrow", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	definition, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return definition, nil
+}
+
+// Encodes the given opcode and operands into a single instruction. Each operand has a fixed width,
+// so an error is returned rather than truncating an operand which does not fit
+func Make(op Opcode, operands ...int) ([]byte, error) {
+	definition, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	if len(operands) != len(definition.OperandWidths) {
+		return nil, fmt.Errorf("wrong number of operands for %s. got=%d, want=%d",
+			definition.Name, len(operands), len(definition.OperandWidths))
+	}
+
+	instructionLength := 1
+	for _, width := range definition.OperandWidths {
+		instructionLength += width
+	}
+
+	instruction := make([]byte, instructionLength)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, operand := range operands {
+		width := definition.OperandWidths[i]
+		if max := 1<<(8*uint(width)) - 1; operand < 0 || operand > max {
+			return nil, fmt.Errorf("operand of %s out of range. got=%d, want at most %d",
+				definition.Name, operand, max)
+		}
+
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction, nil
+}
+
+// Encodes an instruction whose operands are known to fit, such as in tests, panicking otherwise
+func MustMake(op Opcode, operands ...int) []byte {
+	instruction, err := Make(op, operands...)
+	if err != nil {
+		panic(err)
+	}
+
+	return instruction
+}
+
+// Decodes the operands of an instruction, returning the operands and the number of bytes read
+func ReadOperands(definition *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(definition.OperandWidths))
+	offset := 0
+
+	for i, width := range definition.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
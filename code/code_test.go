@@ -0,0 +1,99 @@
+package code
+
+import (
+	"testing"
+	"github.com/alanfoster/monkey/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for _, test := range tests {
+		instruction, err := Make(test.op, test.operands...)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, instruction)
+	}
+}
+
+func TestMakeRejectsOperandsWhichDoNotFit(t *testing.T) {
+	_, err := Make(OpConstant, 65536)
+	assert.EqualError(t, err, "operand of OpConstant out of range. got=65536, want at most 65535")
+
+	_, err = Make(OpCall, 256)
+	assert.EqualError(t, err, "operand of OpCall out of range. got=256, want at most 255")
+
+	_, err = Make(OpGetLocal, -1)
+	assert.EqualError(t, err, "operand of OpGetLocal out of range. got=-1, want at most 255")
+
+	_, err = Make(OpAdd, 1)
+	assert.EqualError(t, err, "wrong number of operands for OpAdd. got=1, want=0")
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, test := range tests {
+		instruction := MustMake(test.op, test.operands...)
+
+		definition, err := Lookup(byte(test.op))
+		assert.NoError(t, err)
+
+		operandsRead, n := ReadOperands(definition, instruction[1:])
+		assert.Equal(t, test.bytesRead, n)
+		assert.Equal(t, test.operands, operandsRead)
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	first := token.Position{Line: 1, Column: 1}
+	second := token.Position{Line: 2, Column: 5}
+	sourceMap := SourceMap{{Offset: 3, Pos: first}, {Offset: 7, Pos: second}}
+
+	assert.Equal(t, token.Position{}, sourceMap.Lookup(0))
+	assert.Equal(t, first, sourceMap.Lookup(3))
+	assert.Equal(t, first, sourceMap.Lookup(6))
+	assert.Equal(t, second, sourceMap.Lookup(7))
+	assert.Equal(t, second, sourceMap.Lookup(100))
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		MustMake(OpAdd),
+		MustMake(OpGetLocal, 1),
+		MustMake(OpConstant, 2),
+		MustMake(OpConstant, 65535),
+		MustMake(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatenated := Instructions{}
+	for _, ins := range instructions {
+		concatenated = append(concatenated, ins...)
+	}
+
+	assert.Equal(t, expected, concatenated.String())
+}
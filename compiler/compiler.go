@@ -0,0 +1,1071 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/code"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/token"
+)
+
+// The opcodes for each infix operator. The vm applies the same operator semantics as the evaluator
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterThanEqual,
+	"<=": code.OpLessThanEqual,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+}
+
+var prefixOpcodes = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+	// The try blocks currently being compiled, holding their finally block or nil when they only
+	// have a catch block
+	tries []*ast.BlockStatement
+	// The source positions of the instructions, used to position the errors raised by the vm
+	sourceMap code.SourceMap
+	// The positions of the instructions which get or set each local slot, which are changed to go
+	// through a cell if an inner closure captures the slot
+	localAccesses map[int][]int
+	// The calls in tail position of the function being compiled
+	tailCalls map[*ast.CallExpression]bool
+}
+
+// The loop currently being compiled, used to resolve the jumps for break and continue
+type loop struct {
+	start      int   // The offset that continue jumps to
+	breakJumps []int // The positions of the jumps which need back-patching to the end of the loop
+	tries      int   // The number of enclosing try blocks outside of the loop
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// The position of the innermost node being compiled, which emitted instructions are mapped to
+	position token.Position
+	// The first instruction which could not be encoded, reported once the node being compiled is
+	// done rather than checked after every emit
+	err error
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// The names of the global bindings indexed by slot, used to report unknown identifiers
+	GlobalNames []string
+	SourceMap   code.SourceMap
+}
+
+func New() *Compiler {
+	return NewWithBuiltins(evaluator.NewBuiltinRegistry())
+}
+
+// Creates a compiler which refers to the builtins of the registry, the vm which runs the bytecode
+// must be given the same registry
+func NewWithBuiltins(builtins *object.BuiltinRegistry) *Compiler {
+	symbolTable := NewSymbolTable()
+	for index, name := range builtins.Names() {
+		symbolTable.DefineBuiltin(index, name)
+	}
+
+	return NewWithState(symbolTable, []object.Object{})
+}
+
+// Creates a compiler which continues on from previously compiled code, as used by the REPL
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{{instructions: code.Instructions{}}},
+		scopeIndex:  0,
+	}
+}
+
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.GlobalNames(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if node == nil {
+		return nil
+	}
+
+	// Instructions are mapped to the innermost node they were compiled from, which is where the
+	// evaluator positions its errors too
+	previous := c.position
+	if pos := node.Pos(); pos.IsValid() {
+		c.position = pos
+	}
+	defer func() { c.position = previous }()
+
+	if err := c.compileNode(node); err != nil {
+		return err
+	}
+	return c.err
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		return c.compileStatements(node.Statements)
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements)
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.LetStatement:
+		// Function literals may refer to their own name, so it must be known when compiling the body
+		if function, ok := node.Value.(*ast.FunctionLiteral); ok {
+			if err := c.compileFunctionLiteral(function, node.Name.Value); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.setSymbol(symbol)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if err := c.exitTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.BigIntLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := c.Compile(element); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpConstant, c.addConstant(evaluator.MemberName(node)))
+		c.emit(code.OpIndex)
+	case *ast.ImportExpression:
+		path := evaluator.ResolveImportPath(node.Path.Value, node.Pos().Filename)
+		c.emit(code.OpImport, c.addConstant(&object.String{Value: path}))
+	case *ast.PrefixExpression:
+		opcode, ok := prefixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(opcode)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		opcode, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(opcode)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		currentLoop := c.currentLoop()
+		if err := c.exitTries(currentLoop.tries); err != nil {
+			return err
+		}
+		currentLoop.breakJumps = append(currentLoop.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		currentLoop := c.currentLoop()
+		if err := c.exitTries(currentLoop.tries); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, currentLoop.start)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+	case *ast.CallExpression:
+		if evaluator.IsQuoteCall(node) && !c.isBound("quote") {
+			return c.compileQuote(node)
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, argument := range node.Arguments {
+			if err := c.Compile(argument); err != nil {
+				return err
+			}
+		}
+
+		if c.scopes[c.scopeIndex].tailCalls[node] {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macros can only be defined by top level let statements")
+	case *ast.BadExpression, *ast.BadStatement:
+		return fmt.Errorf("invalid syntax")
+	default:
+		return fmt.Errorf("unsupported node for compilation: %T", node)
+	}
+
+	return nil
+}
+
+// Whether the name refers to a binding, rather than to a special form such as quote
+func (c *Compiler) isBound(name string) bool {
+	_, ok := c.symbolTable.Resolve(name)
+	return ok
+}
+
+// Quoted nodes are constants. The arguments of any calls to unquote are compiled as expressions,
+// and the vm rebuilds the quoted node with their values
+func (c *Compiler) compileQuote(node *ast.CallExpression) error {
+	if len(node.Arguments) != 1 {
+		return fmt.Errorf("wrong number of arguments to quote. got=%d, want=1", len(node.Arguments))
+	}
+
+	// The arguments are found in the order evaluator.QuoteWithValues replaces the calls
+	var arguments []ast.Expression
+	var err error
+	ast.Modify(node.Arguments[0], func(node ast.Node) ast.Node {
+		if err != nil || !evaluator.IsUnquoteCall(node) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = fmt.Errorf("wrong number of arguments to unquote. got=%d, want=1", len(call.Arguments))
+			return node
+		}
+
+		nested := false
+		ast.Inspect(call.Arguments[0], func(node ast.Node) bool {
+			nested = nested || evaluator.IsUnquoteCall(node)
+			return !nested
+		})
+		if nested {
+			err = fmt.Errorf("unquote within unquote is not supported by the compiler")
+			return node
+		}
+
+		arguments = append(arguments, call.Arguments[0])
+		return node
+	})
+	if err != nil {
+		return err
+	}
+
+	constant := c.addConstant(&object.Quote{Node: node.Arguments[0]})
+	if len(arguments) == 0 {
+		c.emit(code.OpConstant, constant)
+		return nil
+	}
+
+	for _, argument := range arguments {
+		if err := c.Compile(argument); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpQuote, constant, len(arguments))
+
+	return nil
+}
+
+// Compiles a list of statements, leaving the value of the final statement as the last popped
+// value. This mirrors the evaluator where a let statement evaluates to its bound value
+func (c *Compiler) compileStatements(statements []ast.Statement) error {
+	for index, statement := range statements {
+		if err := c.Compile(statement); err != nil {
+			return err
+		}
+
+		if letStatement, ok := statement.(*ast.LetStatement); ok && index == len(statements)-1 {
+			symbol, _ := c.symbolTable.Resolve(letStatement.Name.Value)
+			c.loadSymbol(symbol)
+			c.emit(code.OpPop)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Predicate); err != nil {
+		return err
+	}
+
+	// The jump offsets are not known yet, so placeholders are emitted and later back-patched
+	jumpNotTruthyPosition := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.TrueBlock); err != nil {
+		return err
+	}
+
+	jumpPosition := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+
+	if node.FalseBlock == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.FalseBlock); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPosition, len(c.currentInstructions()))
+
+	return nil
+}
+
+// Compiles && and || with jumps, so that the right operand is skipped when the left operand decides
+// the result. As with the evaluator the result is always a boolean
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	jumpNotTruthyPosition := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "&&" {
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		jumpPosition := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPosition, len(c.currentInstructions()))
+	} else {
+		c.emit(code.OpTrue)
+		jumpPosition := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPosition, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// Compiles an expression converted to a boolean based on its truthiness
+func (c *Compiler) compileTruthiness(node ast.Expression) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPosition := c.emitFor(node.Condition, code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileLoopBody(start, node.Body); err != nil {
+		return err
+	}
+	c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+
+	// Loops evaluate to null
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIterStart)
+
+	start := len(c.currentInstructions())
+	iterNextPosition := c.emitFor(node.Body, code.OpIterNext, 9999)
+	c.setSymbol(c.symbolTable.Define(node.Variable.Value))
+
+	if err := c.compileLoopBody(start, node.Body); err != nil {
+		return err
+	}
+	c.changeOperand(iterNextPosition, len(c.currentInstructions()))
+
+	// Remove the array and index
+	c.emit(code.OpPop)
+	c.emit(code.OpPop)
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// Compiles the body of a loop followed by the jump back to the start. Any break statements jump
+// to the instruction immediately after the body
+func (c *Compiler) compileLoopBody(start int, body *ast.BlockStatement) error {
+	currentLoop := &loop{start: start, tries: len(c.scopes[c.scopeIndex].tries)}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, currentLoop)
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emitFor(body, code.OpJump, start)
+
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+
+	end := len(c.currentInstructions())
+	for _, position := range currentLoop.breakJumps {
+		c.changeOperand(position, end)
+	}
+
+	return nil
+}
+
+// A try with both a catch and a finally block is compiled as a try with a finally block, wrapped
+// around a try with a catch block. Like an if expression, the value of the try remains on the stack
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	if node.FinallyBlock == nil {
+		return c.compileTryCatch(node)
+	}
+
+	tryPosition := c.emit(code.OpTry, 9999)
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, node.FinallyBlock)
+
+	var err error
+	if node.CatchBlock != nil {
+		err = c.compileTryCatch(node)
+	} else {
+		err = c.compileBlockValue(node.Body)
+	}
+	if err != nil {
+		return err
+	}
+
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+	c.emit(code.OpEndTry)
+
+	// The finally block is run both after the try completes and when it raises an error, after which
+	// the error is raised again. It leaves the stack unchanged
+	if err := c.Compile(node.FinallyBlock); err != nil {
+		return err
+	}
+	jumpPosition := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(tryPosition, len(c.currentInstructions()))
+	if err := c.Compile(node.FinallyBlock); err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(jumpPosition, len(c.currentInstructions()))
+
+	return nil
+}
+
+func (c *Compiler) compileTryCatch(node *ast.TryExpression) error {
+	tryPosition := c.emit(code.OpTry, 9999)
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, nil)
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+	c.emit(code.OpEndTry)
+	jumpPosition := c.emit(code.OpJump, 9999)
+
+	// The handler binds the caught exception, which the vm pushed on the stack, for the duration of
+	// the catch block only
+	c.changeOperand(tryPosition, len(c.currentInstructions()))
+	symbol, restore := c.symbolTable.DefineTemporary(node.CatchParameter.Value)
+	c.setSymbol(symbol)
+	err := c.compileBlockValue(node.CatchBlock)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPosition, len(c.currentInstructions()))
+
+	return nil
+}
+
+// Ends the enclosing try blocks of the current function, down to the given number of enclosing try
+// blocks, before jumping out of them with return, break or continue. Their finally blocks are run
+// on the way out, as with the evaluator
+func (c *Compiler) exitTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+
+		// The finally block is no longer within its own try block
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		if tries[i] != nil {
+			if err := c.Compile(tries[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// Compiles a block whose final value must remain on the stack, such as the branches of an if
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var opcode code.Opcode
+	isCompound := node.Operator != "="
+	if isCompound {
+		var ok bool
+		opcode, ok = infixOpcodes[evaluator.CompoundOperator(node.Operator)]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.ResolveAssignable(target.Value)
+		if !ok {
+			symbol = c.symbolTable.global().Define(target.Value)
+		}
+		if symbol.Scope == BuiltinScope || (symbol.Scope == FreeScope && !symbol.Boxed) {
+			return fmt.Errorf("cannot assign to %s", target.Value)
+		}
+
+		if isCompound {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if isCompound {
+			c.emit(opcode)
+		}
+
+		c.assignSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if isCompound {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if isCompound {
+			c.emit(opcode)
+		}
+
+		c.emit(code.OpSetIndex)
+	default:
+		return fmt.Errorf("invalid assignment target %s", node.Target.PrettyPrint())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	compiledFunction, freeSymbols, err := c.compileFunctionBody(node, name)
+	if err != nil {
+		return err
+	}
+
+	for _, symbol := range freeSymbols {
+		c.loadCapturedSymbol(symbol)
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFunction), len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionBody(node *ast.FunctionLiteral, name string) (*object.CompiledFunction, []Symbol, error) {
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	for _, parameter := range node.Parameters {
+		c.symbolTable.Define(parameter.Value)
+	}
+
+	ast.Walk(declarationCollector{c.symbolTable}, node.Body)
+
+	c.scopes[c.scopeIndex].tailCalls = make(map[*ast.CallExpression]bool)
+	collectTailCalls(node.Body, true, c.scopes[c.scopeIndex].tailCalls)
+
+	if err := c.Compile(node.Body); err != nil {
+		return nil, nil, err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+		c.emit(code.OpReturnValue)
+	}
+
+	c.boxCapturedLocals(c.symbolTable.Captured())
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.LocalNames()
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, symbol := range freeSymbols {
+		freeNames[i] = symbol.Name
+	}
+
+	compiledFunction := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		SourceMap:     sourceMap,
+		Literal:       node,
+	}
+
+	return compiledFunction, freeSymbols, nil
+}
+
+// Captured locals are shared with closures through cells, which must be created when the function
+// is entered. Which locals are captured is only known once the body has been compiled, so their
+// instructions are then changed to go through the cells, and the cells are created before the body
+func (c *Compiler) boxCapturedLocals(captured []Symbol) {
+	if len(captured) == 0 {
+		return
+	}
+
+	instructions := c.currentInstructions()
+	for _, symbol := range captured {
+		for _, position := range c.scopes[c.scopeIndex].localAccesses[symbol.Index] {
+			switch code.Opcode(instructions[position]) {
+			case code.OpGetLocal:
+				instructions[position] = byte(code.OpGetLocalCell)
+			case code.OpSetLocal:
+				instructions[position] = byte(code.OpSetLocalCell)
+			}
+		}
+	}
+
+	// Parameters are boxed with the argument they were called with, other locals start unset
+	var prologue code.Instructions
+	for _, symbol := range captured {
+		prologue = append(prologue, code.MustMake(code.OpMakeCell, symbol.Index)...)
+	}
+
+	// Jumps are to absolute offsets, which move along with the body
+	for i := 0; i < len(instructions); {
+		definition, err := code.Lookup(instructions[i])
+		if err != nil {
+			c.err = err
+			return
+		}
+		operands, read := code.ReadOperands(definition, instructions[i+1:])
+
+		switch code.Opcode(instructions[i]) {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext, code.OpTry:
+			c.changeOperand(i, operands[0]+len(prologue))
+		}
+		i += 1 + read
+	}
+
+	sourceMap := code.SourceMap{{Offset: 0, Pos: c.position}}
+	for _, position := range c.scopes[c.scopeIndex].sourceMap {
+		position.Offset += len(prologue)
+		sourceMap = append(sourceMap, position)
+	}
+
+	c.scopes[c.scopeIndex].instructions = append(prologue, instructions...)
+	c.scopes[c.scopeIndex].sourceMap = sourceMap
+}
+
+// Declares the names defined by let statements within a function body, excluding those of nested
+// functions
+type declarationCollector struct {
+	symbolTable *SymbolTable
+}
+
+func (d declarationCollector) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		d.symbolTable.Declare(node.Name.Value)
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return nil
+	}
+
+	return d
+}
+
+// Finds the calls in tail position of a function body, which are the same calls the evaluator
+// makes in tail position: its last statement, the value of a return statement, or the branches of
+// an if expression in tail position
+func collectTailCalls(node ast.Node, tail bool, calls map[*ast.CallExpression]bool) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		// Only calls which are returned are in tail position before the last statement
+		for index, statement := range node.Statements {
+			collectTailCalls(statement, tail && index == len(node.Statements)-1, calls)
+		}
+	case *ast.ExpressionStatement:
+		collectTailCalls(node.Expression, tail, calls)
+	case *ast.ReturnStatement:
+		collectTailCalls(node.Value, true, calls)
+	case *ast.IfExpression:
+		collectTailCalls(node.TrueBlock, tail, calls)
+		if node.FalseBlock != nil {
+			collectTailCalls(node.FalseBlock, tail, calls)
+		}
+	case *ast.CallExpression:
+		if tail {
+			calls[node] = true
+		}
+	}
+}
+
+// Resolves an identifier to a symbol. Identifiers which are not yet known are assumed to be
+// globals defined later on, such as mutually recursive functions, and the vm reports an error
+// if they are still undefined when executed
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+
+	symbol := c.symbolTable.global().Define(name)
+	return symbol
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.addLocalAccess(s, c.emit(code.OpGetLocal, s.Index))
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		if s.Boxed {
+			c.emit(code.OpGetFreeCell, s.Index)
+		} else {
+			c.emit(code.OpGetFree, s.Index)
+		}
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// Loads a symbol which is being captured by a closure. Captured locals are always boxed, and boxed
+// symbols load the cell itself, so that the closure shares the variable rather than copying its
+// current value
+func (c *Compiler) loadCapturedSymbol(s Symbol) {
+	switch {
+	case s.Scope == LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Boxed && s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// Defines the value on top of the stack as the given symbol, as with let statements
+func (c *Compiler) setSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	default:
+		c.addLocalAccess(s, c.emit(code.OpSetLocal, s.Index))
+	}
+}
+
+func (c *Compiler) addLocalAccess(s Symbol, position int) {
+	scope := &c.scopes[c.scopeIndex]
+	if scope.localAccesses == nil {
+		scope.localAccesses = make(map[int][]int)
+	}
+	scope.localAccesses[s.Index] = append(scope.localAccesses[s.Index], position)
+}
+
+// Assigns the value on top of the stack to an existing symbol, leaving the value on the stack as
+// the result of the assignment
+func (c *Compiler) assignSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpSetFreeCell, s.Index)
+	default:
+		c.setSymbol(s)
+	}
+
+	c.loadSymbol(s)
+}
+
+func (c *Compiler) addConstant(o object.Object) int {
+	c.constants = append(c.constants, o)
+	return len(c.constants) - 1
+}
+
+// Emits the given instruction, returning the position of the newly emitted instruction
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	instruction, err := code.Make(op, operands...)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	position := c.addInstruction(instruction)
+
+	c.setLastInstruction(op, position)
+
+	return position
+}
+
+// Emits an instruction mapped to the given node rather than the node being compiled. The loops map
+// the instructions which repeat them to their condition or body, as those are the nodes the
+// evaluator is running when a step or time limit stops it
+func (c *Compiler) emitFor(node ast.Node, op code.Opcode, operands ...int) int {
+	previous := c.position
+	if pos := node.Pos(); pos.IsValid() {
+		c.position = pos
+	}
+	defer func() { c.position = previous }()
+
+	return c.emit(op, operands...)
+}
+
+func (c *Compiler) addInstruction(instruction []byte) int {
+	position := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), instruction...)
+	c.addSourcePosition(position)
+	return position
+}
+
+// Maps the instruction at the offset to the current position, dropping the positions of any
+// instructions which were removed from beyond it
+func (c *Compiler) addSourcePosition(offset int) {
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	for len(sourceMap) > 0 && sourceMap[len(sourceMap)-1].Offset >= offset {
+		sourceMap = sourceMap[:len(sourceMap)-1]
+	}
+
+	if len(sourceMap) == 0 || sourceMap[len(sourceMap)-1].Pos != c.position {
+		sourceMap = append(sourceMap, code.SourcePosition{Offset: offset, Pos: c.position})
+	}
+	c.scopes[c.scopeIndex].sourceMap = sourceMap
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, position int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: position}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPosition := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPosition, code.MustMake(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(position int, newInstruction []byte) {
+	instructions := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		instructions[position+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(position int, operand int) {
+	if c.err != nil {
+		return
+	}
+
+	op := code.Opcode(c.currentInstructions()[position])
+	newInstruction, err := code.Make(op, operand)
+	if err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+
+	c.replaceInstruction(position, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
@@ -0,0 +1,375 @@
+package compiler
+
+import (
+	"testing"
+	"github.com/alanfoster/monkey/code"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/lexer"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func compile(t *testing.T, input string) *Bytecode {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	c := New()
+	assert.NoError(t, c.Compile(program))
+	return c.Bytecode()
+}
+
+func concatInstructions(instructions ...[]byte) code.Instructions {
+	var out code.Instructions
+	for _, instruction := range instructions {
+		out = append(out, instruction...)
+	}
+	return out
+}
+
+func builtinIndex(name string) int {
+	for index, builtinName := range evaluator.BuiltinNames() {
+		if builtinName == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func TestCompilerInstructions(t *testing.T) {
+	tests := []struct {
+		input                string
+		expectedConstants    []object.Object
+		expectedInstructions code.Instructions
+	}{
+		{
+			"1 + 2",
+			[]object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}},
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpAdd),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"1 < 2",
+			[]object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}},
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpLessThan),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"-true",
+			[]object.Object{},
+			concatInstructions(
+				code.MustMake(code.OpTrue),
+				code.MustMake(code.OpMinus),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"~1 ** 2 <= 3",
+			[]object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Integer{Value: 3}},
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpPow),
+				code.MustMake(code.OpBitNot),
+				code.MustMake(code.OpConstant, 2),
+				code.MustMake(code.OpLessThanEqual),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"if (true) { 10 }; 3333;",
+			[]object.Object{&object.Integer{Value: 10}, &object.Integer{Value: 3333}},
+			concatInstructions(
+				code.MustMake(code.OpTrue),
+				code.MustMake(code.OpJumpNotTruthy, 10),
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpJump, 11),
+				code.MustMake(code.OpNull),
+				code.MustMake(code.OpPop),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"let one = 1; let two = one;",
+			[]object.Object{&object.Integer{Value: 1}},
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpSetGlobal, 1),
+				code.MustMake(code.OpGetGlobal, 1),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			`{"a": 1}["a"]`,
+			[]object.Object{&object.String{Value: "a"}, &object.Integer{Value: 1}, &object.String{Value: "a"}},
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpHash, 2),
+				code.MustMake(code.OpConstant, 2),
+				code.MustMake(code.OpIndex),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"while (true) { break; continue; }",
+			[]object.Object{},
+			concatInstructions(
+				code.MustMake(code.OpTrue),
+				code.MustMake(code.OpJumpNotTruthy, 13),
+				code.MustMake(code.OpJump, 13),
+				code.MustMake(code.OpJump, 0),
+				code.MustMake(code.OpJump, 0),
+				code.MustMake(code.OpNull),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"for (x in []) { x }",
+			[]object.Object{},
+			concatInstructions(
+				code.MustMake(code.OpArray, 0),
+				code.MustMake(code.OpIterStart),
+				code.MustMake(code.OpIterNext, 17),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpPop),
+				code.MustMake(code.OpJump, 4),
+				code.MustMake(code.OpPop),
+				code.MustMake(code.OpPop),
+				code.MustMake(code.OpNull),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"len([])",
+			[]object.Object{},
+			concatInstructions(
+				code.MustMake(code.OpGetBuiltin, builtinIndex("len")),
+				code.MustMake(code.OpArray, 0),
+				code.MustMake(code.OpCall, 1),
+				code.MustMake(code.OpPop),
+			),
+		},
+	}
+
+	for _, test := range tests {
+		bytecode := compile(t, test.input)
+		assert.Equal(t, test.expectedInstructions.String(), bytecode.Instructions.String(), test.input)
+		assert.Equal(t, test.expectedConstants, bytecode.Constants, test.input)
+	}
+}
+
+func TestCompilerFunctions(t *testing.T) {
+	tests := []struct {
+		input                string
+		expectedFunction     code.Instructions
+		expectedInstructions code.Instructions
+	}{
+		{
+			"fn() { }",
+			concatInstructions(
+				code.MustMake(code.OpNull),
+				code.MustMake(code.OpReturnValue),
+			),
+			concatInstructions(
+				code.MustMake(code.OpClosure, 0, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"fn(a) { let b = a; }",
+			concatInstructions(
+				code.MustMake(code.OpGetLocal, 0),
+				code.MustMake(code.OpSetLocal, 1),
+				code.MustMake(code.OpGetLocal, 1),
+				code.MustMake(code.OpReturnValue),
+			),
+			concatInstructions(
+				code.MustMake(code.OpClosure, 0, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"let f = fn() { f() };",
+			concatInstructions(
+				code.MustMake(code.OpCurrentClosure),
+				code.MustMake(code.OpTailCall, 0),
+				code.MustMake(code.OpReturnValue),
+			),
+			concatInstructions(
+				code.MustMake(code.OpClosure, 0, 0),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"fn(a) { fn() { a } }",
+			concatInstructions(
+				code.MustMake(code.OpMakeCell, 0),
+				code.MustMake(code.OpGetLocal, 0),
+				code.MustMake(code.OpClosure, 0, 1),
+				code.MustMake(code.OpReturnValue),
+			),
+			concatInstructions(
+				code.MustMake(code.OpClosure, 1, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			// Jumps move along with the body once the cells are created before it
+			"fn(a) { if (a) { a }; fn() { a } }",
+			concatInstructions(
+				code.MustMake(code.OpMakeCell, 0),
+				code.MustMake(code.OpGetLocalCell, 0),
+				code.MustMake(code.OpJumpNotTruthy, 12),
+				code.MustMake(code.OpGetLocalCell, 0),
+				code.MustMake(code.OpJump, 13),
+				code.MustMake(code.OpNull),
+				code.MustMake(code.OpPop),
+				code.MustMake(code.OpGetLocal, 0),
+				code.MustMake(code.OpClosure, 0, 1),
+				code.MustMake(code.OpReturnValue),
+			),
+			concatInstructions(
+				code.MustMake(code.OpClosure, 1, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+	}
+
+	for _, test := range tests {
+		bytecode := compile(t, test.input)
+		assert.Equal(t, test.expectedInstructions.String(), bytecode.Instructions.String(), test.input)
+
+		function, ok := bytecode.Constants[len(bytecode.Constants)-1].(*object.CompiledFunction)
+		if assert.True(t, ok, test.input) {
+			assert.Equal(t, test.expectedFunction.String(), function.Instructions.String(), test.input)
+		}
+	}
+}
+
+func TestCompilerAssignments(t *testing.T) {
+	tests := []struct {
+		input                string
+		expectedInstructions code.Instructions
+	}{
+		{
+			"let x = 1; x = 2;",
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpAssignGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"let x = 1; x += 2;",
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpAdd),
+				code.MustMake(code.OpAssignGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpPop),
+			),
+		},
+		{
+			"let x = [1]; x[0] *= 2;",
+			concatInstructions(
+				code.MustMake(code.OpConstant, 0),
+				code.MustMake(code.OpArray, 1),
+				code.MustMake(code.OpSetGlobal, 0),
+				code.MustMake(code.OpGetGlobal, 0),
+				code.MustMake(code.OpConstant, 1),
+				code.MustMake(code.OpDup2),
+				code.MustMake(code.OpIndex),
+				code.MustMake(code.OpConstant, 2),
+				code.MustMake(code.OpMul),
+				code.MustMake(code.OpSetIndex),
+				code.MustMake(code.OpPop),
+			),
+		},
+	}
+
+	for _, test := range tests {
+		bytecode := compile(t, test.input)
+		assert.Equal(t, test.expectedInstructions.String(), bytecode.Instructions.String(), test.input)
+	}
+}
+
+func TestCompilerCapturedAssignments(t *testing.T) {
+	bytecode := compile(t, "fn() { let count = 0; fn() { count += 1 } }")
+
+	inner := bytecode.Constants[2].(*object.CompiledFunction)
+	expectedInner := concatInstructions(
+		code.MustMake(code.OpGetFreeCell, 0),
+		code.MustMake(code.OpConstant, 1),
+		code.MustMake(code.OpAdd),
+		code.MustMake(code.OpSetFreeCell, 0),
+		code.MustMake(code.OpGetFreeCell, 0),
+		code.MustMake(code.OpReturnValue),
+	)
+	assert.Equal(t, expectedInner.String(), inner.Instructions.String())
+
+	outer := bytecode.Constants[3].(*object.CompiledFunction)
+	expectedOuter := concatInstructions(
+		code.MustMake(code.OpMakeCell, 0),
+		code.MustMake(code.OpConstant, 0),
+		code.MustMake(code.OpSetLocalCell, 0),
+		code.MustMake(code.OpGetLocal, 0),
+		code.MustMake(code.OpClosure, 2, 1),
+		code.MustMake(code.OpReturnValue),
+	)
+	assert.Equal(t, expectedOuter.String(), outer.Instructions.String())
+}
+
+// Each function body is compiled once, however deeply closures which capture locals are nested
+func TestCompilerNestedClosures(t *testing.T) {
+	input := "1"
+	for i := 0; i < 30; i++ {
+		input = "let f = fn() { let y = 1; let g = fn() { y }; " + input + " }; f()"
+	}
+
+	bytecode := compile(t, input)
+	assert.Len(t, bytecode.Constants, 91)
+}
+
+func TestCompilerInvalidAssignments(t *testing.T) {
+	p := parser.New(lexer.New("len = 1"))
+	err := New().Compile(p.ParseProgram())
+	assert.EqualError(t, err, "cannot assign to len")
+}
+
+func TestCompilerInvalidUnquotes(t *testing.T) {
+	p := parser.New(lexer.New("quote(unquote(1, 2))"))
+	err := New().Compile(p.ParseProgram())
+	assert.EqualError(t, err, "wrong number of arguments to unquote. got=2, want=1")
+
+	p = parser.New(lexer.New("quote(unquote(unquote(1)))"))
+	err = New().Compile(p.ParseProgram())
+	assert.EqualError(t, err, "unquote within unquote is not supported by the compiler")
+}
+
+func TestCompilerForwardReferences(t *testing.T) {
+	bytecode := compile(t, "let isEven = fn(n) { isOdd(n) }; let isOdd = fn(n) { n };")
+	assert.Equal(t, []string{"isOdd", "isEven"}, bytecode.GlobalNames)
+}
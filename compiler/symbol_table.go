@@ -0,0 +1,207 @@
+package compiler
+
+import "sort"
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Whether the free variable is stored within an object.Cell, which is shared between the
+	// function which declared it and any closures which captured it. Whether a local is stored
+	// within a cell is only known once its function has been compiled, see Compiler.boxCapturedLocals
+	Boxed bool
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	// The symbols from outer scopes which are captured by a closure, in the order they are
+	// loaded onto the stack when the closure is created
+	FreeSymbols []Symbol
+
+	// The local symbols of this scope which inner closures have captured, by their slot
+	captured map[int]Symbol
+	// The names which are defined somewhere within this scope, which inner functions may refer to
+	// before they are defined
+	declared map[string]bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+		captured:    make(map[int]Symbol),
+		declared:    make(map[string]bool),
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	symbolTable := NewSymbolTable()
+	symbolTable.Outer = outer
+	return symbolTable
+}
+
+// Defines a new symbol within the current scope. Redefining a name re-uses its existing slot, so
+// that any code already compiled against the name sees the new value
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: GlobalScope}
+	if s.Outer != nil {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// Defines a name which is only bound until the returned function is called, such as the parameter
+// of a catch block. The name is given a slot of its own, and any existing binding is restored
+func (s *SymbolTable) DefineTemporary(name string) (Symbol, func()) {
+	previous, ok := s.store[name]
+	delete(s.store, name)
+	symbol := s.Define(name)
+
+	return symbol, func() {
+		if ok {
+			s.store[name] = previous
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Defines the name a function literal was bound to, allowing the function to refer to itself
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	// Captured locals are always boxed, as the enclosing function boxes them once it is compiled
+	boxed := original.Boxed || original.Scope == LocalScope
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope, Boxed: boxed}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Records a name which is defined later within the scope
+func (s *SymbolTable) Declare(name string) {
+	s.declared[name] = true
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return s.resolve(name, false)
+}
+
+func (s *SymbolTable) resolve(name string, enclosed bool) (Symbol, bool) {
+	symbol, ok := s.store[name]
+
+	// An inner function may refer to a local before it is defined, as it is only called later on.
+	// The scope itself may not, as until then the name refers to any outer binding instead
+	if !ok && enclosed && s.declared[name] {
+		symbol, ok = s.Define(name), true
+	}
+
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.resolve(name, true)
+	if !ok {
+		return symbol, ok
+	}
+
+	if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	if symbol.Scope == LocalScope {
+		s.Outer.captured[symbol.Index] = symbol
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Resolves the symbol which an assignment to the given name should update. Within a function a
+// reference to its own name usually loads the current closure, whereas assigning to that name must
+// update the binding the function was defined with
+func (s *SymbolTable) ResolveAssignable(name string) (Symbol, bool) {
+	if symbol, ok := s.store[name]; ok && symbol.Scope == FunctionScope {
+		delete(s.store, name)
+	}
+
+	return s.Resolve(name)
+}
+
+// Returns the local symbols which were captured by inner closures, ordered by their slot
+func (s *SymbolTable) Captured() []Symbol {
+	symbols := make([]Symbol, 0, len(s.captured))
+	for _, symbol := range s.captured {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Index < symbols[j].Index
+	})
+
+	return symbols
+}
+
+// The outermost symbol table, which holds the global bindings
+func (s *SymbolTable) global() *SymbolTable {
+	if s.Outer == nil {
+		return s
+	}
+	return s.Outer.global()
+}
+
+// Returns the names of the local bindings of this scope, indexed by their slot
+func (s *SymbolTable) LocalNames() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope == LocalScope {
+			names[symbol.Index] = name
+		}
+	}
+
+	return names
+}
+
+// Returns the names of the global bindings, indexed by their slot
+func (s *SymbolTable) GlobalNames() []string {
+	global := s.global()
+
+	names := make([]string, global.numDefinitions)
+	for name, symbol := range global.store {
+		if symbol.Scope == GlobalScope {
+			names[symbol.Index] = name
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	assert.Equal(t, Symbol{Name: "a", Scope: GlobalScope, Index: 0}, global.Define("a"))
+	assert.Equal(t, Symbol{Name: "b", Scope: GlobalScope, Index: 1}, global.Define("b"))
+	assert.Equal(t, Symbol{Name: "a", Scope: GlobalScope, Index: 0}, global.Define("a"))
+
+	local := NewEnclosedSymbolTable(global)
+	assert.Equal(t, Symbol{Name: "c", Scope: LocalScope, Index: 0}, local.Define("c"))
+	assert.Equal(t, Symbol{Name: "a", Scope: LocalScope, Index: 1}, local.Define("a"))
+
+	assert.Equal(t, []string{"a", "b"}, local.GlobalNames())
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.DefineFunctionName("self")
+	secondLocal.Define("c")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0, Boxed: true}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{"self", Symbol{Name: "self", Scope: FunctionScope, Index: 0}},
+	}
+
+	for _, test := range tests {
+		symbol, ok := secondLocal.Resolve(test.name)
+		if assert.True(t, ok, test.name) {
+			assert.Equal(t, test.expected, symbol)
+		}
+	}
+
+	assert.Equal(t, []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}, secondLocal.FreeSymbols)
+	assert.Equal(t, []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}, firstLocal.Captured())
+
+	_, ok := secondLocal.Resolve("unknown")
+	assert.False(t, ok)
+}
+
+func TestResolveAssignable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("f")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("f")
+
+	symbol, ok := local.ResolveAssignable("f")
+	if assert.True(t, ok) {
+		assert.Equal(t, Symbol{Name: "f", Scope: GlobalScope, Index: 0}, symbol)
+	}
+}
+
+func TestResolveDeclared(t *testing.T) {
+	global := NewSymbolTable()
+	outer := NewEnclosedSymbolTable(global)
+	outer.Declare("y")
+
+	// The scope which declares the name can not refer to it before it is defined
+	_, ok := outer.Resolve("y")
+	assert.False(t, ok)
+
+	inner := NewEnclosedSymbolTable(outer)
+	symbol, ok := inner.Resolve("y")
+	if assert.True(t, ok) {
+		assert.Equal(t, Symbol{Name: "y", Scope: FreeScope, Index: 0, Boxed: true}, symbol)
+	}
+	assert.Equal(t, Symbol{Name: "y", Scope: LocalScope, Index: 0}, outer.Define("y"))
+}
@@ -3,8 +3,72 @@ package evaluator
 import (
 	"github.com/alanfoster/monkey/object"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// Returns the names of all builtin functions in a stable order, allowing the compiler and vm
+// to refer to builtins by index
+func BuiltinNames() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Creates a registry holding the default builtins, to which host programs can add their own
+func NewBuiltinRegistry() *object.BuiltinRegistry {
+	return NewBuiltinRegistryWithStreams(object.NewStandardStreams())
+}
+
+// Creates a registry holding the default builtins, with builtins such as puts using the streams
+// rather than those of the process
+func NewBuiltinRegistryWithStreams(streams *object.Streams) *object.BuiltinRegistry {
+	streamBuiltins := newStreamBuiltins(streams)
+
+	registry := object.NewBuiltinRegistry()
+	for _, name := range BuiltinNames() {
+		if builtin, ok := streamBuiltins[name]; ok {
+			registry.Register(name, builtin.Fn)
+		} else {
+			registry.Register(name, builtins[name].Fn)
+		}
+	}
+
+	return registry
+}
+
+func init() {
+	for name, builtin := range newStreamBuiltins(object.NewStandardStreams()) {
+		builtins[name] = builtin
+	}
+}
+
+// Creates the builtins which read from or write to the streams
+func newStreamBuiltins(streams *object.Streams) map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"puts": {
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(streams.Stdout, arg.Inspect())
+				}
+
+				return NULL
+			},
+		},
+	}
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -104,13 +168,168 @@ var builtins = map[string]*object.Builtin{
 			return nil
 		},
 	},
-	"puts": {
+	"keys": {
 		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			return NULL
+			switch arg := args[0].(type) {
+			case *object.Hash:
+				elements := []object.Object{}
+				for _, pair := range arg.Entries() {
+					elements = append(elements, pair.Key)
+				}
+
+				return &object.Array{Elements: elements}
+			default:
+				return newError("argument to `keys` must be %s, got %s", object.HASH, arg.Type())
+			}
+		},
+	},
+	"values": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Hash:
+				elements := []object.Object{}
+				for _, pair := range arg.Entries() {
+					elements = append(elements, pair.Value)
+				}
+
+				return &object.Array{Elements: elements}
+			default:
+				return newError("argument to `values` must be %s, got %s", object.HASH, arg.Type())
+			}
+		},
+	},
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("first argument to `has` must be %s, got %s", object.HASH, args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, exists := hash.Get(key)
+			return asBoolean(exists)
+		},
+	},
+	// Returns a new hash without the given key and leaves the original unchanged, whereas index
+	// assignment updates a hash in place
+	"delete": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("first argument to `delete` must be %s, got %s", object.HASH, args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			deletedKey := key.HashKey()
+			newHash := object.NewHash()
+			for _, pair := range hash.Entries() {
+				pairKey := pair.Key.(object.Hashable)
+				if pairKey.HashKey() != deletedKey {
+					newHash.Set(pairKey, pair.Value)
+				}
+			}
+
+			return newHash
+		},
+	},
+	// Stops the program, which is unwound like an error that cannot be handled
+	"exit": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+
+			code := int64(0)
+			if len(args) == 1 {
+				integer, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `exit` must be %s, got %s", object.INTEGER, args[0].Type())
+				}
+				code = integer.Value
+			}
+			if code < 0 || code > 255 {
+				return newError("exit code must be between 0 and 255, got %d", code)
+			}
+
+			return &object.Error{Message: fmt.Sprintf("exit status %d", code), Exited: true, ExitCode: int(code)}
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.BigInt:
+				if !arg.Value.IsInt64() {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: arg.Value.Int64()}
+			case *object.Float:
+				// Conversion truncates towards zero
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) || math.Abs(arg.Value) >= math.MaxInt64 {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError("could not parse %q as integer", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("argument to `int` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInt:
+				value, _ := toFloat(arg)
+				return &object.Float{Value: value}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("could not parse %q as float", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s", arg.Type())
+			}
 		},
 	},
 }
@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/object"
+	"strings"
+)
+
+// Reports whether two objects are structurally equal. Numbers are equal when their values are,
+// whatever their type. Arrays are equal when their elements are, in order, and hashes are equal
+// when they have the same keys with equal values. Other objects are only equal to themselves
+func Equal(left object.Object, right object.Object) bool {
+	return equal(left, right, map[comparedPair]bool{})
+}
+
+// A pair of containers which are being compared. Containers may hold themselves, so a pair which
+// is already being compared is taken to be equal rather than compared forever
+type comparedPair struct {
+	left  object.Object
+	right object.Object
+}
+
+func equal(left object.Object, right object.Object, compared map[comparedPair]bool) bool {
+	if left == right {
+		return true
+	}
+
+	if isNumber(left) && isNumber(right) {
+		result, errorObject := compareNumbers(left, right)
+		return errorObject == nil && result == 0
+	}
+
+	pair := comparedPair{left, right}
+	if compared[pair] {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		right, ok := right.(*object.String)
+		return ok && left.Value == right.Value
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		compared[pair] = true
+		for i, element := range left.Elements {
+			if !equal(element, right.Elements[i], compared) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		compared[pair] = true
+		for hashKey, entry := range left.Pairs {
+			other, ok := right.Pairs[hashKey]
+			if !ok || !equal(entry.Value, other.Value, compared) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Orders two objects, returning a negative number when left sorts before right, zero when they are
+// equal and a positive number otherwise. Numbers are ordered by value, strings byte by byte, and
+// arrays element by element with shorter arrays first. Other objects cannot be ordered
+func Compare(left object.Object, right object.Object) (int, *object.Error) {
+	return compare(left, right, map[comparedPair]bool{})
+}
+
+func compare(left object.Object, right object.Object, compared map[comparedPair]bool) (int, *object.Error) {
+	if isNumber(left) && isNumber(right) {
+		return compareNumbers(left, right)
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		if right, ok := right.(*object.String); ok {
+			return strings.Compare(left.Value, right.Value), nil
+		}
+	case *object.Array:
+		if right, ok := right.(*object.Array); ok {
+			pair := comparedPair{left, right}
+			if compared[pair] {
+				return 0, nil
+			}
+			compared[pair] = true
+
+			for i := 0; i < len(left.Elements) && i < len(right.Elements); i++ {
+				result, errorObject := compare(left.Elements[i], right.Elements[i], compared)
+				if errorObject != nil || result != 0 {
+					return result, errorObject
+				}
+			}
+			return len(left.Elements) - len(right.Elements), nil
+		}
+	}
+
+	return 0, newError("cannot compare %s with %s", left.Type(), right.Type())
+}
+
+func isNumber(o object.Object) bool {
+	switch o.(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+func compareNumbers(left object.Object, right object.Object) (int, *object.Error) {
+	if left, ok := left.(*object.Integer); ok {
+		if right, ok := right.(*object.Integer); ok {
+			switch {
+			case left.Value < right.Value:
+				return -1, nil
+			case left.Value > right.Value:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	if left.Type() != object.FLOAT && right.Type() != object.FLOAT {
+		first, _ := toBigInt(left)
+		second, _ := toBigInt(right)
+		return first.Cmp(second), nil
+	}
+
+	first, _ := toFloat(left)
+	second, _ := toFloat(right)
+	switch {
+	case first < second:
+		return -1, nil
+	case first > second:
+		return 1, nil
+	case first == second:
+		return 0, nil
+	default:
+		return 0, newError("cannot compare %s with %s", left.Inspect(), right.Inspect())
+	}
+}
+
+// Applies a comparison operator to the result of Compare
+func compareResult(operator string, result int) (object.Object, bool) {
+	switch operator {
+	case "<":
+		return asBoolean(result < 0), true
+	case ">":
+		return asBoolean(result > 0), true
+	case "<=":
+		return asBoolean(result <= 0), true
+	case ">=":
+		return asBoolean(result >= 0), true
+	case "==":
+		return asBoolean(result == 0), true
+	case "!=":
+		return asBoolean(result != 0), true
+	default:
+		return nil, false
+	}
+}
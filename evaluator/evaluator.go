@@ -3,25 +3,42 @@ package evaluator
 import (
 	"github.com/alanfoster/monkey/ast"
 	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/token"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// Converts a Go panic recovered while running a program into an error, so that an internal failure
+// stops the program rather than the whole process
+func NewInternalError(recovered interface{}) *object.Error {
+	return newError("internal error: %v", recovered)
+}
+
 func isError(o object.Object) bool {
 	return o != nil && o.Type() == object.ERROR
 }
 
-func evalProgram(statements []ast.Statement, environment *object.Environment) object.Object {
-	var result object.Object
+func evalProgram(statements []ast.Statement, environment *object.Environment) (result object.Object) {
+	// Programs are the entrypoint of evaluation, including for modules, so no panic escapes them
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = NewInternalError(recovered)
+		}
+	}()
 
 	for _, statement := range statements {
 		result = Eval(statement, environment)
@@ -44,8 +61,9 @@ func evalBlockStatement(statements []ast.Statement, environment *object.Environm
 	for _, statement := range statements {
 		result = Eval(statement, environment)
 
-		// Return the wrapped return value, as we may be nested in multiple block statements
-		if rt := result.Type(); rt == object.RETURN_VALUE || rt == object.ERROR {
+		// Return the wrapped return value, as we may be nested in multiple block statements.
+		// Similarly break and continue are passed up to the enclosing loop
+		if rt := result.Type(); rt == object.RETURN_VALUE || rt == object.ERROR || rt == object.BREAK || rt == object.CONTINUE {
 			return result
 		}
 	}
@@ -73,43 +91,133 @@ func evalBangPrefixOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
+func evalMinusPrefixOperatorExpression(right object.Object, checked bool) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			if checked {
+				return newError("integer overflow: -(%d)", right.Value)
+			}
+			return &object.BigInt{Value: new(big.Int).Neg(big.NewInt(right.Value))}
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Neg(right.Value)}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Not(right.Value)}
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+// Applies a prefix operator to an already evaluated operand. Exported so that the vm shares
+// the exact same operator semantics as the evaluator. Checked operators report integer overflow
+// rather than promoting the result to a big integer
+func EvalPrefixExpression(operator string, right object.Object, checked bool) object.Object {
 	switch operator {
 	case "!":
 		return evalBangPrefixOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, checked)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left object.Integer, right object.Integer) object.Object {
+func evalIntegerInfixExpression(operator string, left object.Integer, right object.Integer, checked bool) object.Object {
 	leftVal := left.Value
 	rightVal := right.Value
 
+	// Results which overflow are promoted to a big integer, unless overflow is checked
+	overflow := func() object.Object {
+		if checked {
+			return newError("integer overflow: %d %s %d", leftVal, operator, rightVal)
+		}
+		return evalBigIntInfixExpression(operator, big.NewInt(leftVal), big.NewInt(rightVal))
+	}
+
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		result := leftVal + rightVal
+		if leftVal > 0 && rightVal > 0 && result < 0 || leftVal < 0 && rightVal < 0 && result >= 0 {
+			return overflow()
+		}
+		return &object.Integer{Value: result}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		result := leftVal - rightVal
+		if leftVal >= 0 && rightVal < 0 && result < 0 || leftVal < 0 && rightVal > 0 && result >= 0 {
+			return overflow()
+		}
+		return &object.Integer{Value: result}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		result := leftVal * rightVal
+		if leftVal != 0 && (result/leftVal != rightVal || leftVal == -1 && rightVal == math.MinInt64) {
+			return overflow()
+		}
+		return &object.Integer{Value: result}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return overflow()
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% %d", leftVal, rightVal)
+		}
+		// The remainder has the sign of the dividend, matching division truncating towards zero
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		result := evalBigIntInfixExpression(operator, big.NewInt(leftVal), big.NewInt(rightVal))
+		if power, ok := result.(*object.BigInt); ok && power.Value.IsInt64() {
+			return &object.Integer{Value: power.Value.Int64()}
+		} else if ok && checked {
+			return overflow()
+		}
+		return result
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d << %d", leftVal, rightVal)
+		}
+		if leftVal != 0 && (rightVal >= 64 || leftVal<<uint64(rightVal)>>uint64(rightVal) != leftVal) {
+			return overflow()
+		}
+		return &object.Integer{Value: leftVal << uint64(rightVal)}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d >> %d", leftVal, rightVal)
+		}
+		// Shifts are arithmetic, keeping the sign of negative integers
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
 	case ">":
 		return asBoolean(leftVal > rightVal)
 	case "<":
 		return asBoolean(leftVal < rightVal)
+	case ">=":
+		return asBoolean(leftVal >= rightVal)
+	case "<=":
+		return asBoolean(leftVal <= rightVal)
 	case "==":
 		return asBoolean(leftVal == rightVal)
 	case "!=":
@@ -119,6 +227,127 @@ func evalIntegerInfixExpression(operator string, left object.Integer, right obje
 	}
 }
 
+// Results of ** and << on big integers with more bits than this are rejected, rather than
+// exhausting memory
+const maxBigIntBits = 1 << 24
+
+func evalBigIntInfixExpression(operator string, left *big.Int, right *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return &object.BigInt{Value: new(big.Int).Add(left, right)}
+	case "-":
+		return &object.BigInt{Value: new(big.Int).Sub(left, right)}
+	case "*":
+		return &object.BigInt{Value: new(big.Int).Mul(left, right)}
+	case "/":
+		if right.Sign() == 0 {
+			return newError("division by zero: %s / %s", left, right)
+		}
+		// Division truncates towards zero, the same as for integers
+		return &object.BigInt{Value: new(big.Int).Quo(left, right)}
+	case "%":
+		if right.Sign() == 0 {
+			return newError("division by zero: %s %% %s", left, right)
+		}
+		return &object.BigInt{Value: new(big.Int).Rem(left, right)}
+	case "**":
+		if right.Sign() < 0 {
+			return newError("negative exponent: %s ** %s", left, right)
+		}
+		if left.CmpAbs(big.NewInt(1)) > 0 && (!right.IsInt64() || right.Int64() > maxBigIntBits || int64(left.BitLen()-1)*right.Int64() > maxBigIntBits) {
+			return newError("big integer too large: %s ** %s", left, right)
+		}
+		return &object.BigInt{Value: new(big.Int).Exp(left, right, nil)}
+	case "&":
+		return &object.BigInt{Value: new(big.Int).And(left, right)}
+	case "|":
+		return &object.BigInt{Value: new(big.Int).Or(left, right)}
+	case "^":
+		return &object.BigInt{Value: new(big.Int).Xor(left, right)}
+	case "<<":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s << %s", left, right)
+		}
+		if left.Sign() != 0 && (!right.IsInt64() || right.Int64() > maxBigIntBits || int64(left.BitLen())+right.Int64() > maxBigIntBits) {
+			return newError("big integer too large: %s << %s", left, right)
+		}
+		if left.Sign() == 0 {
+			return &object.BigInt{Value: new(big.Int)}
+		}
+		return &object.BigInt{Value: new(big.Int).Lsh(left, uint(right.Int64()))}
+	case ">>":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s >> %s", left, right)
+		}
+		// Shifting by more than the length of the integer leaves 0, or -1 when it is negative
+		shift := uint(left.BitLen() + 1)
+		if right.IsInt64() && right.Int64() < int64(shift) {
+			shift = uint(right.Int64())
+		}
+		return &object.BigInt{Value: new(big.Int).Rsh(left, shift)}
+	case ">":
+		return asBoolean(left.Cmp(right) > 0)
+	case "<":
+		return asBoolean(left.Cmp(right) < 0)
+	case ">=":
+		return asBoolean(left.Cmp(right) >= 0)
+	case "<=":
+		return asBoolean(left.Cmp(right) <= 0)
+	case "==":
+		return asBoolean(left.Cmp(right) == 0)
+	case "!=":
+		return asBoolean(left.Cmp(right) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", object.BIGINT, operator, object.BIGINT)
+	}
+}
+
+func evalFloatInfixExpression(operator string, left float64, right float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: left + right}
+	case "-":
+		return &object.Float{Value: left - right}
+	case "*":
+		return &object.Float{Value: left * right}
+	case "/":
+		return &object.Float{Value: left / right}
+	case "%":
+		return &object.Float{Value: math.Mod(left, right)}
+	case "**":
+		return &object.Float{Value: math.Pow(left, right)}
+	case ">":
+		return asBoolean(left > right)
+	case "<":
+		return asBoolean(left < right)
+	case ">=":
+		return asBoolean(left >= right)
+	case "<=":
+		return asBoolean(left <= right)
+	case "==":
+		return asBoolean(left == right)
+	case "!=":
+		return asBoolean(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT, operator, object.FLOAT)
+	}
+}
+
+// Converts an integer or float to a float, for arithmetic which mixes the two
+func toFloat(o object.Object) (float64, bool) {
+	switch o := o.(type) {
+	case *object.Integer:
+		return float64(o.Value), true
+	case *object.BigInt:
+		value, _ := new(big.Float).SetInt(o.Value).Float64()
+		return value, true
+	case *object.Float:
+		return o.Value, true
+	default:
+		return 0, false
+	}
+}
+
 func evalStringInfixExpression(operator string, left object.String, right object.String) object.Object {
 	leftVal := left.Value
 	rightVal := right.Value
@@ -130,33 +359,88 @@ func evalStringInfixExpression(operator string, left object.String, right object
 		return asBoolean(leftVal == rightVal)
 	case "!=":
 		return asBoolean(leftVal != rightVal)
-	default:
+	}
+
+	// Strings are ordered lexicographically, byte by byte
+	if result, ok := compareResult(operator, strings.Compare(leftVal, rightVal)); ok {
+		return result
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalArrayInfixExpression(operator string, left *object.Array, right *object.Array) object.Object {
+	switch operator {
+	case "==":
+		return asBoolean(Equal(left, right))
+	case "!=":
+		return asBoolean(!Equal(left, right))
+	}
+
+	// Arrays are ordered lexicographically, element by element
+	result, errorObject := Compare(left, right)
+	comparison, ok := compareResult(operator, result)
+	if !ok {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
+	if errorObject != nil {
+		return errorObject
+	}
+	return comparison
+}
+
+// Converts an integer or big integer to a big integer, for arithmetic which mixes the two
+func toBigInt(o object.Object) (*big.Int, bool) {
+	switch o := o.(type) {
+	case *object.Integer:
+		return big.NewInt(o.Value), true
+	case *object.BigInt:
+		return o.Value, true
+	default:
+		return nil, false
+	}
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+// Applies an infix operator to already evaluated operands, shared with the vm. Checked operators
+// report integer overflow rather than promoting the result to a big integer
+func EvalInfixExpression(operator string, left object.Object, right object.Object, checked bool) object.Object {
 	switch {
+	case left.Type() == object.FLOAT || right.Type() == object.FLOAT:
+		first, isLeftNumber := toFloat(left)
+		second, isRightNumber := toFloat(right)
+		if !isLeftNumber || !isRightNumber {
+			return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		}
+		return evalFloatInfixExpression(operator, first, second)
+	case left.Type() == object.BIGINT || right.Type() == object.BIGINT:
+		first, isLeftInteger := toBigInt(left)
+		second, isRightInteger := toBigInt(right)
+		if !isLeftInteger || !isRightInteger {
+			return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		}
+		return evalBigIntInfixExpression(operator, first, second)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
 		first := *left.(*object.Integer)
 		second := *right.(*object.Integer)
-		return evalIntegerInfixExpression(operator, first, second)
+		return evalIntegerInfixExpression(operator, first, second, checked)
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		first := *left.(*object.String)
 		second := *right.(*object.String)
 		return evalStringInfixExpression(operator, first, second)
+	case left.Type() == object.ARRAY && right.Type() == object.ARRAY:
+		return evalArrayInfixExpression(operator, left.(*object.Array), right.(*object.Array))
 	case operator == "==":
-		return asBoolean(left == right)
+		return asBoolean(Equal(left, right))
 	case operator == "!=":
-		return asBoolean(left != right)
+		return asBoolean(!Equal(left, right))
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func isTruthy(o object.Object) bool {
+// Only false and null are considered falsy, shared with the vm
+func IsTruthy(o object.Object) bool {
 	switch o {
 	case FALSE:
 		return false
@@ -169,6 +453,24 @@ func isTruthy(o object.Object) bool {
 	}
 }
 
+// Evaluates && and || lazily, the right operand is only evaluated when the left operand does not
+// already decide the result
+func evalLogicalExpression(operator string, left object.Object, rightNode ast.Expression, environment *object.Environment) object.Object {
+	if operator == "&&" && !IsTruthy(left) {
+		return FALSE
+	}
+	if operator == "||" && IsTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(rightNode, environment)
+	if isError(right) {
+		return right
+	}
+
+	return asBoolean(IsTruthy(right))
+}
+
 func evalIfExpression(node *ast.IfExpression, environment *object.Environment) object.Object {
 	result := Eval(node.Predicate, environment)
 
@@ -176,7 +478,7 @@ func evalIfExpression(node *ast.IfExpression, environment *object.Environment) o
 		return result
 	}
 
-	if isTruthy(result) {
+	if IsTruthy(result) {
 		return Eval(node.TrueBlock, environment)
 	} else if node.FalseBlock != nil {
 		return Eval(node.FalseBlock, environment)
@@ -185,35 +487,255 @@ func evalIfExpression(node *ast.IfExpression, environment *object.Environment) o
 	}
 }
 
+func evalWhileStatement(node *ast.WhileStatement, environment *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, environment)
+		if isError(condition) {
+			return condition
+		}
+
+		if !IsTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(node.Body, environment)
+		if result, stop := unwrapLoopResult(result); stop {
+			return result
+		}
+	}
+}
+
+// The loop variable is bound within the current environment, the same as a let statement
+func evalForStatement(node *ast.ForStatement, environment *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, environment)
+	if isError(iterable) {
+		return iterable
+	}
+
+	array, ok := iterable.(*object.Array)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, element := range array.Elements {
+		environment.Add(node.Variable.Value, element)
+
+		result := Eval(node.Body, environment)
+		if result, stop := unwrapLoopResult(result); stop {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// Decides whether a loop should stop after evaluating its body, and the value the loop results in
+func unwrapLoopResult(result object.Object) (object.Object, bool) {
+	switch result.Type() {
+	case object.BREAK:
+		return NULL, true
+	case object.RETURN_VALUE, object.ERROR:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
 func evalIdentifier(node *ast.Identifier, environment *object.Environment) object.Object {
 	if value, ok := environment.Get(node.Value); ok {
 		return value
 	}
 
-	if value, ok := builtins[node.Value]; ok {
+	if registry := environment.Builtins(); registry != nil {
+		if value, ok := registry.Lookup(node.Value); ok {
+			return value
+		}
+	} else if value, ok := builtins[node.Value]; ok {
 		return value
 	}
 
 	return newError("identifier not found: %s", node.Value)
 }
 
-func evalIndexExpression(left object.Object, index object.Object) object.Object {
+// Indexes into an already evaluated array or hash, shared with the vm
+func EvalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
-	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+	case left.Type() == object.ARRAY && isInteger(index):
 		array := left.(*object.Array)
-		i := index.(*object.Integer).Value
-		isIndexMissing := i < 0 || i > int64(len(array.Elements))
+		i, ok := arrayIndex(index)
+		isIndexMissing := !ok || i < 0 || i >= int64(len(array.Elements))
 
 		if isIndexMissing {
 			return NULL
 		} else {
 			return array.Elements[i]
 		}
+	case left.Type() == object.HASH:
+		return evalHashIndexExpression(left.(*object.Hash), index)
+	case left.Type() == object.MODULE && index.Type() == object.STRING:
+		module := left.(*object.Module)
+		name := index.(*object.String).Value
+
+		if value, ok := module.Exports[name]; ok {
+			return value
+		}
+		return newError("module %s has no export %s", module.Name, name)
+	case left.Type() == object.EXCEPTION && index.Type() == object.STRING:
+		return evalExceptionMember(left.(*object.Exception), index.(*object.String).Value)
 	default:
 		return newError("index operator not available with value %s and index %s", left.Type(), index.Type())
 	}
 }
 
+func isInteger(o object.Object) bool {
+	return o.Type() == object.INTEGER || o.Type() == object.BIGINT
+}
+
+// Returns the value of an integer used as an array index, false when it is too large to index any
+// array
+func arrayIndex(index object.Object) (int64, bool) {
+	switch index := index.(type) {
+	case *object.Integer:
+		return index.Value, true
+	case *object.BigInt:
+		return index.Value.Int64(), index.Value.IsInt64()
+	default:
+		return 0, false
+	}
+}
+
+func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	if value, ok := hash.Get(key); ok {
+		return value
+	}
+
+	return NULL
+}
+
+// Accessing a member is equivalent to indexing with its name, i.e. `lib.name` is `lib["name"]`
+func MemberName(node *ast.MemberExpression) *object.String {
+	return &object.String{Value: node.Property.Value}
+}
+
+// Updates an element of an already evaluated array or hash in place, shared with the vm
+func EvalIndexAssignment(left object.Object, index object.Object, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY && isInteger(index):
+		array := left.(*object.Array)
+		i, ok := arrayIndex(index)
+		if !ok || i < 0 || i >= int64(len(array.Elements)) {
+			return newError("index out of range: %s", index.Inspect())
+		}
+
+		array.Elements[i] = value
+		return value
+	case left.Type() == object.HASH:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Set(key, value)
+		return value
+	default:
+		return newError("index assignment not available with value %s and index %s", left.Type(), index.Type())
+	}
+}
+
+// The infix operator applied by a compound assignment, i.e. `+` for `+=`
+func CompoundOperator(assignOperator string) string {
+	return strings.TrimSuffix(assignOperator, "=")
+}
+
+func evalAssignExpression(node *ast.AssignExpression, environment *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := environment.Get(target.Value)
+		if !ok {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+
+		value := Eval(node.Value, environment)
+		if isError(value) {
+			return value
+		}
+
+		if node.Operator != "=" {
+			value = EvalInfixExpression(CompoundOperator(node.Operator), current, value, environment.Limits().ChecksOverflow())
+			if isError(value) {
+				return value
+			}
+		}
+
+		environment.Set(target.Value, value)
+		return value
+	case *ast.IndexExpression:
+		left := Eval(target.Left, environment)
+		if isError(left) {
+			return left
+		}
+
+		index := Eval(target.Index, environment)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = EvalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(node.Value, environment)
+		if isError(value) {
+			return value
+		}
+
+		if node.Operator != "=" {
+			value = EvalInfixExpression(CompoundOperator(node.Operator), current, value, environment.Limits().ChecksOverflow())
+			if isError(value) {
+				return value
+			}
+		}
+
+		return EvalIndexAssignment(left, index, value)
+	default:
+		return newError("invalid assignment target %s", node.Target.PrettyPrint())
+	}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, environment *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, environment)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, environment)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
 func evalArrayLiteral(node *ast.ArrayLiteral, environment *object.Environment) object.Object {
 	elements, errorObject := evalExpressions(node.Elements, environment)
 	if errorObject != nil {
@@ -251,12 +773,22 @@ func unwrapResult(o object.Object) object.Object {
 	return o
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(fn object.Object, args []object.Object, callSite token.Position) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		scopedEnvironment := extendFunctionEnvironment(fn, args)
-		evaluated := Eval(fn.Body, scopedEnvironment)
-		return unwrapResult(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+
+		limits := fn.Environment.Limits()
+		if errorObject := limits.Enter(); errorObject != nil {
+			return errorObject
+		}
+
+		evaluated := callFunction(fn, args, callSite)
+		limits.Leave()
+
+		return evaluated
 
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -267,6 +799,10 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 }
 
 func evalCallExpression(node *ast.CallExpression, environment *object.Environment) object.Object {
+	if isQuoteCall(node, environment) {
+		return evalQuoteCall(node, environment)
+	}
+
 	function := Eval(node.Function, environment)
 	if isError(function) {
 		return function
@@ -277,10 +813,26 @@ func evalCallExpression(node *ast.CallExpression, environment *object.Environmen
 		return errorObject
 	}
 
-	return applyFunction(function, argumentValues)
+	return applyFunction(function, argumentValues, node.Pos())
 }
 
 func Eval(node ast.Node, environment *object.Environment) object.Object {
+	if errorObject := environment.Limits().Step(); errorObject != nil {
+		errorObject.Pos = node.Pos()
+		return errorObject
+	}
+
+	result := evalNode(node, environment)
+
+	// Errors are positioned at the innermost node which produced them
+	if errorObject, ok := result.(*object.Error); ok && !errorObject.Pos.IsValid() {
+		errorObject.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func evalNode(node ast.Node, environment *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node.Statements, environment)
@@ -288,12 +840,18 @@ func Eval(node ast.Node, environment *object.Environment) object.Object {
 		return Eval(node.Expression, environment)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.BigIntLiteral:
+		return &object.BigInt{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return asBoolean(node.Value)
 	case *ast.ArrayLiteral:
 		return evalArrayLiteral(node, environment)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, environment)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, environment)
 		if isError(left) {
@@ -305,28 +863,57 @@ func Eval(node ast.Node, environment *object.Environment) object.Object {
 			return index
 		}
 
-		return evalIndexExpression(left, index)
+		return EvalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		left := Eval(node.Object, environment)
+		if isError(left) {
+			return left
+		}
+
+		return EvalIndexExpression(left, MemberName(node))
+	case *ast.ImportExpression:
+		return evalImportExpression(node, environment)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, environment)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return EvalPrefixExpression(node.Operator, right, environment.Limits().ChecksOverflow())
 	case *ast.InfixExpression:
 		left := Eval(node.Left, environment)
 		if isError(left) {
 			return left
 		}
 
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, environment)
+		}
+
 		right := Eval(node.Right, environment)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return EvalInfixExpression(node.Operator, left, right, environment.Limits().ChecksOverflow())
 	case *ast.IfExpression:
 		return evalIfExpression(node, environment)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, environment)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, environment)
+	case *ast.ForStatement:
+		return evalForStatement(node, environment)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ThrowStatement:
+		value := Eval(node.Value, environment)
+		if isError(value) {
+			return value
+		}
+		return Throw(value)
+	case *ast.TryExpression:
+		return evalTryExpression(node, environment)
 	case *ast.ReturnStatement:
 		value := Eval(node.Value, environment)
 		if isError(value) {
@@ -339,14 +926,25 @@ func Eval(node ast.Node, environment *object.Environment) object.Object {
 			return value
 		}
 
+		// Functions are named after the binding they were first assigned to, for use in stack traces
+		if function, ok := value.(*object.Function); ok && function.Name == "" {
+			function.Name = node.Name.Value
+		}
+
 		environment.Add(node.Name.Value, value)
 		return value
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, environment)
 	case *ast.Identifier:
 		return evalIdentifier(node, environment)
 	case *ast.FunctionLiteral:
 		return &object.Function{Parameters: node.Parameters, Body: node.Body, Environment: environment}
 	case *ast.CallExpression:
 		return evalCallExpression(node, environment)
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top level let statements")
+	case *ast.BadExpression, *ast.BadStatement:
+		return newError("invalid syntax")
 	}
 
 	panic(fmt.Sprintf("Unexpected value %#v", node))
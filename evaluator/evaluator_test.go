@@ -1,11 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"math"
+	"math/big"
+	"path/filepath"
 	"testing"
+	"github.com/alanfoster/monkey/ast"
 	"github.com/alanfoster/monkey/object"
 	"github.com/alanfoster/monkey/lexer"
 	"github.com/alanfoster/monkey/parser"
 	"github.com/stretchr/testify/assert"
+	"time"
 )
 
 func assertIntegerObject(t *testing.T, o object.Object, expected int64) {
@@ -16,6 +23,14 @@ func assertIntegerObject(t *testing.T, o object.Object, expected int64) {
 	}
 }
 
+func assertFloatObject(t *testing.T, o object.Object, expected float64) {
+	assert.IsType(t, new(object.Float), o)
+	result, ok := o.(*object.Float)
+	if assert.True(t, ok) {
+		assert.Equal(t, expected, result.Value)
+	}
+}
+
 func assertBooleanObject(t *testing.T, o object.Object, expected bool) {
 	assert.IsType(t, new(object.Boolean), o)
 	result, ok := o.(*object.Boolean)
@@ -123,6 +138,257 @@ func TestEvalIntegerOperatorExpressions(t *testing.T) {
 	}
 }
 
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e3", 1000.0},
+		{"0.5 + 0.25", 0.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5", 7.5},
+		{"1.0 / 0", math.Inf(1)},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{"let total = 0; for (x in [1, 2.5, 3]) { total += x }; total / 3", 6.5 / 3},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`"a" * 1.5`, "type mismatch: STRING * FLOAT"},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case float64:
+			assertFloatObject(t, evaluated, expected)
+		case bool:
+			assertBooleanObject(t, evaluated, expected)
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBigIntExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10n", "10n"},
+		{"-10n", "-10n"},
+		{"123456789012345678901234567890", "123456789012345678901234567890n"},
+		{"2n + 3", "5n"},
+		{"3 - 5n", "-2n"},
+		{"9223372036854775807 * 9223372036854775807", "85070591730234615847396907784232501249n"},
+		{"-7n / 2", "-3n"},
+		{"1n / 0n", "ERROR: division by zero: 1 / 0"},
+		{"10n * 0.5", "5.0"},
+		{"1n == 1", "true"},
+		{"1n != 2", "true"},
+		{"9223372036854775808 > 9223372036854775807", "true"},
+		{"-9223372036854775809 < -9223372036854775808", "true"},
+		{"let total = 0; for (x in [9223372036854775807, 1, -1]) { total += x }; total", "9223372036854775807n"},
+		{`1n + "a"`, "ERROR: type mismatch: BIGINT + STRING"},
+		{"1n && true", "true"},
+		{"[1n, 2]", "[1n, 2]"},
+		{"int(5n)", "5"},
+		{"int(9223372036854775808)", "ERROR: cannot convert 9223372036854775808n to INTEGER"},
+		{"float(3n)", "3.0"},
+		{"quote(unquote(2n * 5))", "QUOTE(10n)"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"[1, 2, 3][1n]", "2"},
+		{"[1, 2, 3][100000000000000000000n]", "null"},
+		{"let xs = [1, 2]; xs[1n] = 5; xs", "[1, 5]"},
+		{"let xs = [1, 2]; xs[100000000000000000000n] = 5", "ERROR: index out of range: 100000000000000000000n"},
+		{`{10: "a"}[10n]`, "a"},
+		{`let h = {10n: "a"}; h[5 + 5]`, "a"},
+		{`let h = {}; h[9223372036854775807 + 1] = "a"; h[9223372036854775808n]`, "a"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, eval(t, test.input).Inspect(), test.input)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 <= 2", "true"},
+		{"3 <= 2", "false"},
+		{"2 >= 3", "false"},
+		{"2.5 >= 2", "true"},
+		{"10n <= 9", "false"},
+		{`"a" <= "a"`, "true"},
+		{`"b" >= "c"`, "false"},
+		{"[1, 2] <= [1, 2]", "true"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7 % -3", "1"},
+		{"7.5 % 2", "1.5"},
+		{"7 % 0", "ERROR: division by zero: 7 % 0"},
+		{"100000000000000000000 % 7", "2n"},
+		{"2 ** 10", "1024"},
+		{"2 ** 3 ** 2", "512"},
+		{"-2 ** 2", "-4"},
+		{"(-2) ** 3", "-8"},
+		{"2 ** 0", "1"},
+		{"2 ** 64", "18446744073709551616n"},
+		{"2 ** -1", "ERROR: negative exponent: 2 ** -1"},
+		{"2.0 ** -1", "0.5"},
+		{"4 ** 0.5", "2.0"},
+		{"3n ** 3", "27n"},
+		{"2 ** 100000000", "ERROR: big integer too large: 2 ** 100000000"},
+		{"12 & 10", "8"},
+		{"12 | 10", "14"},
+		{"12 ^ 10", "6"},
+		{"~0", "-1"},
+		{"~5n", "-6n"},
+		{"1 << 4", "16"},
+		{"-16 >> 2", "-4"},
+		{"1 >> 64", "0"},
+		{"1 << 63", "9223372036854775808n"},
+		{"0 << 100", "0"},
+		{"3 << 62 >> 62", "3n"},
+		{"1 << -1", "ERROR: negative shift count: 1 << -1"},
+		{"-1n >> 1000", "-1n"},
+		{"(1n << 100) & (1 << 100 | 1)", "1267650600228229401496703205376n"},
+		{"let flags = 1 | 4; [flags & 4 != 0, flags & 2 != 0]", "[true, false]"},
+		{"1.5 & 1", "ERROR: unknown operator: FLOAT & FLOAT"},
+		{"~1.5", "ERROR: unknown operator: ~FLOAT"},
+		{`"a" % "b"`, "ERROR: unknown operator: STRING % STRING"},
+		{"true <= false", "ERROR: unknown operator: BOOLEAN <= BOOLEAN"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, eval(t, test.input).Inspect(), test.input)
+	}
+}
+
+func TestStructuralComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2] == [1, 2]", "true"},
+		{"[1, 2] != [1, 2]", "false"},
+		{"[1, 2] == [2, 1]", "false"},
+		{"[1, [2, 3]] == [1, [2, 3]]", "true"},
+		{"[1, 2] == [1.0, 2n]", "true"},
+		{`[1] == ["1"]`, "false"},
+		{"[] == []", "true"},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 2}`, "false"},
+		{`{"a": 1} != {"b": 1}`, "true"},
+		{"let f = fn() { 1 }; [f] == [f]", "true"},
+		{"[fn() { 1 }] == [fn() { 1 }]", "false"},
+		{"[if (false) { 1 }, true] == [if (false) { 1 }, true]", "true"},
+		{`"a" < "b"`, "true"},
+		{`"b" > "abc"`, "true"},
+		{`"abc" < "ab"`, "false"},
+		{`"B" < "a"`, "true"},
+		{"[1, 2] < [1, 3]", "true"},
+		{"[1, 2] < [1, 2, 0]", "true"},
+		{"[2] > [1, 5]", "true"},
+		{`[["a"], 1] < [["b"], 0]`, "true"},
+		{"[1, 2] < [1, 2]", "false"},
+		{`[1] < ["a"]`, "ERROR: cannot compare INTEGER with STRING"},
+		{"[true] < [false]", "ERROR: cannot compare BOOLEAN with BOOLEAN"},
+		{"[1] + [2]", "ERROR: unknown operator: ARRAY + ARRAY"},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; [a == b, a == a, a < b, a <= b]", "[true, true, false, true]"},
+		{`let a = {"x": 1}; a["x"] = a; let b = {"x": 1}; b["x"] = b; a == b`, "true"},
+		{"let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; [a == b, a < b]", "[false, true]"},
+		{`{"a": 1} < {"a": 2}`, "ERROR: unknown operator: HASH < HASH"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, eval(t, test.input).Inspect(), test.input)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		left     object.Object
+		right    object.Object
+		expected int
+	}{
+		{&object.Integer{Value: 1}, &object.Integer{Value: 2}, -1},
+		{&object.Float{Value: 2.5}, &object.Integer{Value: 2}, 1},
+		{&object.BigInt{Value: big.NewInt(3)}, &object.Float{Value: 3}, 0},
+		{&object.String{Value: "b"}, &object.String{Value: "a"}, 1},
+		{&object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}, &object.Array{}, 1},
+	}
+
+	for _, test := range tests {
+		result, errorObject := Compare(test.left, test.right)
+		assert.Nil(t, errorObject)
+		assert.Equal(t, test.expected, result, test.left.Inspect()+" "+test.right.Inspect())
+	}
+
+	_, errorObject := Compare(&object.Float{Value: math.NaN()}, &object.Integer{Value: 1})
+	assert.Equal(t, "cannot compare NaN with 1", errorObject.Message)
+	_, errorObject = Compare(TRUE, FALSE)
+	assert.Equal(t, "cannot compare BOOLEAN with BOOLEAN", errorObject.Message)
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"2.0", "2.0"},
+		{"1 * 4.0", "4.0"},
+		{"1e21", "1e+21"},
+		{"1.0 / 0", "+Inf"},
+		{"[1, 1.5]", "[1, 1.5]"},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assert.Equal(t, test.expected, evaluated.Inspect(), test.input)
+	}
+}
+
+func TestNumberConversionFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(3.99)", int64(3)},
+		{"int(-3.99)", int64(-3)},
+		{"int(7)", int64(7)},
+		{`int("42")`, int64(42)},
+		{"float(2)", 2.0},
+		{"float(2.5)", 2.5},
+		{`float("1e3")`, 1000.0},
+		{"int(1.0 / 0)", "cannot convert +Inf to INTEGER"},
+		{`int("4.2")`, `could not parse "4.2" as integer`},
+		{`float("abc")`, `could not parse "abc" as float`},
+		{"int(true)", "argument to `int` not supported, got BOOLEAN"},
+		{"float([])", "argument to `float` not supported, got ARRAY"},
+		{"int(1, 2)", "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case int64:
+			assertIntegerObject(t, evaluated, expected)
+		case float64:
+			assertFloatObject(t, evaluated, expected)
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -394,6 +660,14 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"10 / 0",
+			"division by zero: 10 / 0",
+		},
+		{
+			"let x = 10; x /= 0",
+			"division by zero: 10 / 0",
+		},
 		{
 			"-true;",
 			"unknown operator: -BOOLEAN",
@@ -454,6 +728,39 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"if (false) { 1 } || 0", true},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let calls = 0; let f = fn() { calls += 1; true }; false && f(); true || f(); calls", 0},
+		{"true && undefined", "identifier not found: undefined"},
+		{"undefined || true", "identifier not found: undefined"},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case bool:
+			assertBooleanObject(t, evaluated, expected)
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestAssignmentHandling(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -867,6 +1174,10 @@ func TestArrayExpressions(t *testing.T) {
 			`[1, 2, 3][2]`,
 			3,
 		},
+		{
+			`[1, 2, 3][3]`,
+			nil,
+		},
 		{
 			`[1, 2, 3][4]`,
 			nil,
@@ -895,3 +1206,933 @@ func TestArrayExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHashLiterals(t *testing.T) {
+	input := `
+		let two = "two";
+		{
+			"one": 10 - 9,
+			two: 1 + 1,
+			"thr" + "ee": 6 / 2,
+			4: 4,
+			true: 5,
+			false: 6
+		}
+	`
+
+	evaluated := eval(t, input)
+	result, ok := evaluated.(*object.Hash)
+	assert.True(t, ok)
+	if ok {
+		expected := map[object.HashKey]int64{
+			(&object.String{Value: "one"}).HashKey():   1,
+			(&object.String{Value: "two"}).HashKey():   2,
+			(&object.String{Value: "three"}).HashKey(): 3,
+			(&object.Integer{Value: 4}).HashKey():      4,
+			TRUE.HashKey():                             5,
+			FALSE.HashKey():                            6,
+		}
+
+		assert.Equal(t, len(expected), len(result.Pairs))
+		for expectedKey, expectedValue := range expected {
+			pair, ok := result.Pairs[expectedKey]
+			if assert.True(t, ok) {
+				assertIntegerObject(t, pair.Value, expectedValue)
+			}
+		}
+
+		assert.Equal(t, "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}", result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+		{
+			`{"foo": 1, "foo": 2}["foo"]`,
+			2,
+		},
+		{
+			`{"foo": 5}[[]]`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`{[]: 5}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`{fn(x) { x }: 5}`,
+			"unusable as hash key: FUNCTION",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		default:
+			assertNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPutsWritesToStreams(t *testing.T) {
+	var stdout bytes.Buffer
+	environment := object.NewEnvironmentWithBuiltins(NewBuiltinRegistryWithStreams(&object.Streams{Stdout: &stdout}))
+
+	program := parser.New(lexer.New(`puts(1, "two", [3]); puts()`)).ParseProgram()
+	assertNullObject(t, Eval(program, environment))
+	assert.Equal(t, "1\ntwo\n[3]\n", stdout.String())
+}
+
+func TestExitFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"exit()", 0},
+		{"exit(3)", 3},
+		{"let f = fn() { exit(1); 2 }; f(); 3", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := eval(t, tt.input)
+		if assert.IsType(t, &object.Error{}, evaluated, tt.input) {
+			assert.True(t, evaluated.(*object.Error).Exited, tt.input)
+			assert.Equal(t, tt.expected, evaluated.(*object.Error).ExitCode, tt.input)
+		}
+	}
+
+	assertErrorObject(t, eval(t, `exit("1")`), "argument to `exit` must be INTEGER, got STRING")
+	assertErrorObject(t, eval(t, "exit(1, 2)"), "wrong number of arguments. got=2, want=0 or 1")
+	assertErrorObject(t, eval(t, "exit(256)"), "exit code must be between 0 and 255, got 256")
+	assertErrorObject(t, eval(t, "exit(-1)"), "exit code must be between 0 and 255, got -1")
+}
+
+func TestHashFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Hash Usage
+		{
+			`keys({})`,
+			"[]",
+		},
+		{
+			`keys({"a": 1, 2: "b", true: 3})`,
+			"[a, 2, true]",
+		},
+		{
+			`values({"a": 1, 2: "b", true: 3})`,
+			"[1, b, 3]",
+		},
+		{
+			`has({"a": 1}, "a")`,
+			"true",
+		},
+		{
+			`has({"a": 1}, "b")`,
+			"false",
+		},
+		{
+			`delete({"a": 1, "b": 2, "c": 3}, "b")`,
+			"{a: 1, c: 3}",
+		},
+		{
+			`delete({"a": 1}, "b")`,
+			"{a: 1}",
+		},
+		{
+			`let original = {"a": 1}; delete(original, "a"); original`,
+			"{a: 1}",
+		},
+
+		// Invalid Usage
+		{
+			`keys([1])`,
+			"ERROR: argument to `keys` must be HASH, got ARRAY",
+		},
+		{
+			`values()`,
+			"ERROR: wrong number of arguments. got=0, want=1",
+		},
+		{
+			`has({}, [])`,
+			"ERROR: unusable as hash key: ARRAY",
+		},
+		{
+			`delete("a", "b")`,
+			"ERROR: first argument to `delete` must be HASH, got STRING",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assert.Equal(t, test.expected, evaluated.Inspect())
+	}
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"x",
+			nil,
+		},
+		{
+			"let f = fn() { x }; f()",
+			[]string{"in f, called at 1:21"},
+		},
+		{
+			`
+let inner = fn(a) { a + true }
+let outer = fn() {
+  inner(1)
+}
+outer()
+`,
+			[]string{
+				"in inner, called at 4:3",
+				"in outer, called at 6:1",
+			},
+		},
+		{
+			"let apply = fn(f) { f() }; apply(fn() { len(1) })",
+			[]string{
+				"in <anonymous>, called at 1:21",
+				"in apply, called at 1:28",
+			},
+		},
+		{
+			"let f = fn() { x }; let g = f; g()",
+			[]string{"in f, called at 1:32"},
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		result, ok := evaluated.(*object.Error)
+		if assert.True(t, ok) {
+			var frames []string
+			for _, frame := range result.Stack {
+				frames = append(frames, frame.String())
+			}
+			assert.Equal(t, test.expected, frames, test.input)
+		}
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	evaluated := eval(t, "let f = fn(n) { 1 + f(n + 1) }; f(0)")
+	assertErrorObject(t, evaluated, "maximum recursion depth exceeded")
+	assert.Len(t, evaluated.(*object.Error).Stack, object.DefaultMaxDepth)
+	assert.Equal(t, "    in f, called at 1:21\n    in f, called at 1:21\n    in f, called at 1:21\n"+
+		"    ... repeated 9996 more times\n    in f, called at 1:33\n", evaluated.(*object.Error).StackTrace())
+
+	program := parser.New(lexer.New("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)")).ParseProgram()
+	tests := []struct {
+		maxDepth int
+		expected string
+	}{
+		{11, "10"},
+		{10, "ERROR: maximum recursion depth exceeded"},
+	}
+
+	for _, tt := range tests {
+		environment := object.NewEnvironment()
+		environment.SetLimits(&object.Limits{MaxDepth: tt.maxDepth})
+		assert.Equal(t, tt.expected, Eval(program, environment).Inspect())
+	}
+
+	// The depth is unwound after an error, so later calls are unaffected
+	environment := object.NewEnvironment()
+	assertErrorObject(t, Eval(parser.New(lexer.New("let f = fn() { 1 + f() }; f()")).ParseProgram(), environment), "maximum recursion depth exceeded")
+	assertIntegerObject(t, Eval(parser.New(lexer.New("let g = fn() { 1 }; g()")).ParseProgram(), environment), 1)
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input     string
+		unchecked string
+		checked   string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808n", "ERROR: integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "-9223372036854775809n", "ERROR: integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "9223372036854775808n", "ERROR: integer overflow: 4611686018427387904 * 2"},
+		{"-1 * (-9223372036854775807 - 1)", "9223372036854775808n", "ERROR: integer overflow: -1 * -9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "9223372036854775808n", "ERROR: integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "9223372036854775808n", "ERROR: integer overflow: -(-9223372036854775808)"},
+		{"3037000500 ** 2", "9223372037000250000n", "ERROR: integer overflow: 3037000500 ** 2"},
+		{"1 << 63", "9223372036854775808n", "ERROR: integer overflow: 1 << 63"},
+		{"let x = 9223372036854775807; x += 1", "9223372036854775808n", "ERROR: integer overflow: 9223372036854775807 + 1"},
+		{"3037000499 * 3037000499", "9223372030926249001", "9223372030926249001"},
+		{"-9223372036854775807 - 1", "-9223372036854775808", "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		assert.Equal(t, tt.unchecked, Eval(program, object.NewEnvironment()).Inspect(), tt.input)
+
+		environment := object.NewEnvironment()
+		environment.SetLimits(&object.Limits{CheckOverflow: true})
+		assert.Equal(t, tt.checked, Eval(program, environment).Inspect(), tt.input)
+	}
+}
+
+func TestInternalErrorsAreRecovered(t *testing.T) {
+	registry := NewBuiltinRegistry()
+	registry.Register("fail", func(args ...object.Object) object.Object {
+		panic("unexpected failure")
+	})
+	environment := object.NewEnvironmentWithBuiltins(registry)
+
+	assertErrorObject(t, Eval(parser.New(lexer.New("1 + fail()")).ParseProgram(), environment), "internal error: unexpected failure")
+	assertIntegerObject(t, Eval(parser.New(lexer.New("1 + 1")).ParseProgram(), environment), 2)
+}
+
+func TestTailCalls(t *testing.T) {
+	elements := make([]object.Object, object.DefaultMaxDepth+1000)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: 1}
+	}
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`let reduce = fn(array, initial, reducer) {
+				let iter = fn(array, acc) {
+					if (len(array) == 0) { acc } else { iter(rest(array), reducer(acc, first(array))) }
+				};
+				iter(array, initial);
+			};
+			reduce(elements, 0, fn(acc, next) { acc + next })`,
+			int64(len(elements)),
+		},
+		{"let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); }; count(100000)", 0},
+		{"let count = fn(n, acc) { if (n > 0) { return count(n - 1, acc + 1); } acc }; count(100000, 0)", 100000},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; if (isEven(100000)) { 1 } else { 0 }", 1},
+		{"let count = fn(n) { if (n == 0) { 0 } else { let next = n - 1; count(next) } }; count(100000)", 0},
+	}
+
+	for _, tt := range tests {
+		environment := object.NewEnvironment()
+		environment.Add("elements", &object.Array{Elements: elements})
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), environment)
+		assertIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestTailCallStackTraces(t *testing.T) {
+	input := `
+let fail = fn(n) { n + true }
+let count = fn(n) { if (n == 0) { fail(n) } else { count(n - 1) } }
+count(3)
+`
+
+	evaluated := eval(t, input)
+	assertErrorObject(t, evaluated, "type mismatch: INTEGER + BOOLEAN")
+	assert.Equal(t, "    in fail, called at 3:35\n    ... 3 tail calls omitted\n    in count, called at 4:1\n", evaluated.(*object.Error).StackTrace())
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 + true } catch (e) { e.message }", "type mismatch: INTEGER + BOOLEAN"},
+		{"try { x } catch (e) { e.payload }", "identifier not found: x"},
+		{`try { throw "bad" } catch (e) { e.message }`, "bad"},
+		{`try { throw {"code": 1} } catch (e) { [e.message, e.payload["code"]] }`, "[{code: 1}, 1]"},
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{"let x = try { throw 1 } catch (e) { e.payload + 1 }; x", "2"},
+		{"try { throw 1 } catch (e) { e }", "exception: 1"},
+		{"try { throw 1 } catch (e) { e.missing }", "ERROR: exception has no member missing"},
+		{"try { 5 } finally { 6 }", "5"},
+		{"let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log", "[1, 2]"},
+		{`let log = []; let f = fn() { try { throw "x" } finally { log = push(log, "finally") } }; try { f() } catch (e) { log = push(log, e.message) }; log`, "[finally, x]"},
+		{"let log = []; let f = fn() { try { return 1 } finally { log = push(log, 2) } }; [f(), log]", "[1, [2]]"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", "2"},
+		{"let f = fn() { try { throw 1 } finally { return 2 } }; f()", "2"},
+		{`let log = []; for (x in [1, 2, 3]) { try { if (x == 2) { break } log = push(log, x) } finally { log = push(log, "f") } }; log`, "[1, f, f]"},
+		{`let log = []; for (x in [1, 2, 3]) { try { if (x == 2) { continue } log = push(log, x) } finally { log = push(log, "f") } }; log`, "[1, f, f, 3, f]"},
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e.message }`, "inner"},
+		{`let log = []; try { try { throw 1 } catch (e) { throw 2 } finally { log = push(log, "f") } } catch (e) { push(log, e.payload) }`, "[f, 2]"},
+		{`throw "oops"`, "ERROR: oops"},
+		{"let f = fn() { 1 + true }; let g = fn() { f() + 1 }; try { g() } catch (e) { e.message }", "type mismatch: INTEGER + BOOLEAN"},
+		{"try { exit(1) } catch (e) { 2 }", "ERROR: exit status 1"},
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { e.message }", "maximum recursion depth exceeded"},
+		{"let f = fn() { let i = 0; while (true) { try { i += 1; if (i == 3) { return i } } catch (e) { } } }; f()", "3"},
+		{`let f = fn(x) { if (x > 2) { throw "too big" } x }; let total = 0; for (x in [1, 2, 3, 4]) { try { total += f(x) } catch (e) { total += 100 } }; total`, "203"},
+		{`let f = fn() { let g = fn() { throw "x" }; try { g() } catch (e) { return e.message } }; f()`, "x"},
+		{"try { throw 1 } catch (e) { e }; e.payload", "ERROR: identifier not found: e"},
+		{`let e = 5; try { throw "x" } catch (e) { 1 }; e`, "5"},
+		{"let f = fn() { let e = 5; let r = try { throw 1 } catch (e) { e.payload }; [r, e] }; f()", "[1, 5]"},
+		{"let e = 0; let g = 0; try { throw 1 } catch (e) { g = fn() { e.payload } }; [g(), e]", "[1, 0]"},
+		{"let f = fn() { let g = 0; try { throw 1 } catch (e) { g = fn() { e.payload } }; let e = 2; [g(), e] }; f()", "[1, 2]"},
+		{"try { throw 1 } catch (e) { let y = e.payload + 1 }; y", "2"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, eval(t, tt.input).Inspect(), tt.input)
+	}
+}
+
+func TestExceptionStacks(t *testing.T) {
+	input := `
+let fail = fn() { throw "x" }
+let f = fn() { fail() + 1 }
+try { f() } catch (e) { e.stack }
+`
+
+	assert.Equal(t, "[in fail, called at 3:16, in f, called at 4:7]", eval(t, input).Inspect())
+}
+
+func TestInterruptedErrorsCannotBeCaught(t *testing.T) {
+	program := parser.New(lexer.New("let caught = false; try { while (true) { } } catch (e) { caught = true } finally { caught = true }")).ParseProgram()
+
+	limits := &object.Limits{MaxSteps: 1000}
+	environment := object.NewEnvironment()
+	environment.SetLimits(limits)
+	limits.Start(context.Background())
+
+	assertErrorObject(t, Eval(program, environment), "execution limit exceeded")
+	caught, _ := environment.Get("caught")
+	assertBooleanObject(t, caught, false)
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"fn(x, y) { x + y }(1)",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"fn() { 1 }(1, 2)",
+			"wrong number of arguments. got=2, want=0",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assertErrorObject(t, evaluated, test.expected)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"while (false) { 1 }",
+			nil,
+		},
+		{
+			"let i = 0; while (i < 5) { let i = i + 1; }; i",
+			5,
+		},
+		{
+			"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i",
+			3,
+		},
+		{
+			`
+				let i = 0;
+				let evens = 0;
+				while (i < 10) {
+					let i = i + 1;
+					if (i / 2 * 2 != i) { continue; }
+					let evens = evens + 1;
+				}
+				evens
+			`,
+			5,
+		},
+		{
+			"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; }; sum",
+			6,
+		},
+		{
+			"for (x in [1, 2, 3]) { }; x",
+			3,
+		},
+		{
+			"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let sum = sum + x; }; sum",
+			4,
+		},
+		{
+			"let find = fn(xs) { for (x in xs) { if (x > 1) { return x; } }; -1 }; find([1, 5, 7])",
+			5,
+		},
+		{
+			"let total = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y == 20) { break; } let total = total + x * y; } }; total",
+			30,
+		},
+		{
+			"for (x in 5) { }",
+			"cannot iterate over INTEGER",
+		},
+		{
+			"while (true) { 1 + true }",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"while (x) { }",
+			"identifier not found: x",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		default:
+			assertNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLoopsDoNotGrowTheStack(t *testing.T) {
+	input := `
+		let i = 0;
+		while (i < 100000) { let i = i + 1; }
+		i
+	`
+
+	evaluated := eval(t, input)
+	assertIntegerObject(t, evaluated, 100000)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn() { while (true) { } }; f()")).ParseProgram()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		limits   *object.Limits
+		ctx      context.Context
+		expected string
+	}{
+		{&object.Limits{MaxSteps: 1000}, context.Background(), "execution limit exceeded"},
+		{&object.Limits{Timeout: time.Millisecond}, context.Background(), "execution limit exceeded"},
+		{&object.Limits{}, cancelled, "cancelled"},
+	}
+
+	for _, tt := range tests {
+		environment := object.NewEnvironment()
+		environment.SetLimits(tt.limits)
+		tt.limits.Start(tt.ctx)
+
+		evaluated := Eval(program, environment)
+		assertErrorObject(t, evaluated, tt.expected)
+		assert.True(t, evaluated.(*object.Error).Interrupted)
+	}
+}
+
+func TestExecutionLimitsAreRestartedForEachRun(t *testing.T) {
+	limits := &object.Limits{MaxSteps: 100}
+	environment := object.NewEnvironment()
+	environment.SetLimits(limits)
+
+	for i := 0; i < 3; i++ {
+		limits.Start(context.Background())
+		program := parser.New(lexer.New("let x = 1 + 2; x")).ParseProgram()
+		assertIntegerObject(t, Eval(program, environment), 3)
+	}
+}
+
+func TestReassignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"let x = 1; x = 2; x",
+			2,
+		},
+		{
+			"let x = 1; x = 2",
+			2,
+		},
+		{
+			"let a = 1; let b = 2; a = b = 3; a + b",
+			6,
+		},
+		{
+			"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x",
+			6,
+		},
+		{
+			"let count = 0; let increment = fn() { count += 1 }; increment(); increment(); count",
+			2,
+		},
+		{
+			"let counter = fn() { let count = 0; fn() { count = count + 1 } }; let c = counter(); c(); c()",
+			2,
+		},
+		{
+			"let x = 1; let f = fn() { let x = 5; x = 10; x }; f() + x",
+			11,
+		},
+		{
+			"let i = 0; while (i < 5) { i += 1 }; i",
+			5,
+		},
+		{
+			"x = 5",
+			"cannot assign to undeclared identifier: x",
+		},
+		{
+			"let f = fn() { y += 1 }; f()",
+			"cannot assign to undeclared identifier: y",
+		},
+		{
+			"let x = 1; x += true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"len = 1",
+			"cannot assign to undeclared identifier: len",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		switch expected := test.expected.(type) {
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let xs = [1, 2, 3]; xs[1] = 5; xs",
+			"[1, 5, 3]",
+		},
+		{
+			"let xs = [1, 2, 3]; xs[0] += 10",
+			"11",
+		},
+		{
+			"let xs = [1, 2]; let ys = xs; ys[0] = 9; xs",
+			"[9, 2]",
+		},
+		{
+			"let grid = [[1, 2], [3, 4]]; grid[1][0] *= 10; grid",
+			"[[1, 2], [30, 4]]",
+		},
+		{
+			"let xs = [1, 2]; xs[0] = xs; xs",
+			"[[...], 2]",
+		},
+		{
+			`let h = {"a": 1}; h["b"] = [h, h]; h`,
+			"{a: 1, b: [{...}, {...}]}",
+		},
+		{
+			"let x = [1]; let xs = [x, x]; xs",
+			"[[1], [1]]",
+		},
+		{
+			`let h = {"a": 1}; h["b"] = 2; h["a"] -= 1; h`,
+			"{a: 0, b: 2}",
+		},
+		{
+			"let xs = [1]; xs[1] = 2",
+			"ERROR: index out of range: 1",
+		},
+		{
+			"let xs = [1]; xs[-1] = 2",
+			"ERROR: index out of range: -1",
+		},
+		{
+			`let h = {}; h[fn() {}] = 1`,
+			"ERROR: unusable as hash key: FUNCTION",
+		},
+		{
+			`let s = "abc"; s[0] = "d"`,
+			"ERROR: index assignment not available with value STRING and index INTEGER",
+		},
+		{
+			`let h = {}; h["a"] += 1`,
+			"ERROR: type mismatch: NULL + INTEGER",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assert.Equal(t, test.expected, evaluated.Inspect(), test.input)
+	}
+}
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let math = import "testdata/modules/math.monkey"; math.area(2)`,
+			"12",
+		},
+		{
+			`let math = import "testdata/modules/math.monkey"; math["pi"]`,
+			"3",
+		},
+		{
+			`import "testdata/modules/math.monkey"`,
+			"<module testdata/modules/math.monkey>",
+		},
+		{
+			`let lib = import "testdata/modules/nested/uses_parent.monkey"; lib.double_area(1)`,
+			"6",
+		},
+		{
+			`let a = import "testdata/modules/counter.monkey"; let b = import "testdata/modules/counter.monkey"; a.increment(); b.increment(); a.current()`,
+			"2",
+		},
+		{
+			`let math = import "testdata/modules/math.monkey"; math._square`,
+			"ERROR: module testdata/modules/math.monkey has no export _square",
+		},
+		{
+			`let math = import "testdata/modules/math.monkey"; math.missing`,
+			"ERROR: module testdata/modules/math.monkey has no export missing",
+		},
+		{
+			`import "testdata/modules/cycle_a.monkey"`,
+			"ERROR: import cycle: testdata/modules/cycle_a.monkey -> testdata/modules/cycle_b.monkey -> testdata/modules/cycle_a.monkey",
+		},
+		{
+			`import "testdata/modules/missing.monkey"`,
+			`ERROR: could not import "testdata/modules/missing.monkey": open testdata/modules/missing.monkey: no such file or directory`,
+		},
+		{
+			`import "testdata/modules/broken.monkey"`,
+			`ERROR: could not import "testdata/modules/broken.monkey": testdata/modules/broken.monkey:1:9: no prefix parse function for ; found`,
+		},
+		{
+			`import "testdata/modules/failing.monkey"`,
+			"ERROR: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`let h = {"name": "monkey"}; h.name`,
+			"monkey",
+		},
+		{
+			`let lib = import "testdata/modules/macros.monkey"; [lib.sign(1), lib.sign(-1)]`,
+			"[positive, negative]",
+		},
+		{
+			"5.name",
+			"ERROR: index operator not available with value INTEGER and index STRING",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assert.Equal(t, test.expected, evaluated.Inspect(), test.input)
+	}
+}
+
+func TestModulesAreLoadedOnceWhateverTheirPath(t *testing.T) {
+	absolute, err := filepath.Abs("testdata/modules/counter.monkey")
+	assert.NoError(t, err)
+
+	input := `let a = import "testdata/modules/counter.monkey"; let b = import "` + absolute + `"; a.increment(); b.increment(); a.current()`
+	assert.Equal(t, "2", eval(t, input).Inspect())
+}
+
+func TestImportPathsAreRelativeToTheImporter(t *testing.T) {
+	assert.Equal(t, "lib.monkey", ResolveImportPath("lib.monkey", ""))
+	assert.Equal(t, "examples/lib.monkey", ResolveImportPath("lib.monkey", "examples/main.monkey"))
+	assert.Equal(t, "lib.monkey", ResolveImportPath("../lib.monkey", "examples/main.monkey"))
+	assert.Equal(t, "/lib.monkey", ResolveImportPath("/lib.monkey", "examples/main.monkey"))
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "QUOTE(5)"},
+		{"quote(5 + 8)", "QUOTE((5 + 8))"},
+		{"quote(foobar + barfoo)", "QUOTE((foobar + barfoo))"},
+		{"quote(unquote(4 + 4))", "QUOTE(8)"},
+		{"quote(8 + unquote(4 + 4))", "QUOTE((8 + 8))"},
+		{"let x = 8; quote(x + unquote(x))", "QUOTE((x + 8))"},
+		{"quote(unquote(true == false))", "QUOTE(false)"},
+		{`quote(unquote("monkey"))`, "QUOTE(monkey)"},
+		{"quote(unquote(1.5))", "QUOTE(1.5)"},
+		{"quote(unquote(quote(4 + 4)))", "QUOTE((4 + 4))"},
+		{"let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))", "QUOTE((8 + (4 + 4)))"},
+		// Each evaluation of a quote is independent of the previous evaluations
+		{"let f = fn(n) { quote(unquote(n)) }; f(1); f(2)", "QUOTE(2)"},
+		{"quote(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+		{"quote(unquote([1]))", "ERROR: cannot unquote ARRAY"},
+		{"quote(unquote(x))", "ERROR: identifier not found: x"},
+		// Bindings named quote shadow it
+		{"let quote = fn(x) { x * 2 }; quote(21)", "42"},
+		{"let f = fn(quote) { quote(21) }; f(fn(x) { x * 2 })", "42"},
+	}
+
+	for _, test := range tests {
+		evaluated := eval(t, test.input)
+		assert.Equal(t, test.expected, evaluated.Inspect(), test.input)
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+	return program
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+		let number = 1;
+		let function = fn(x, y) { x + y };
+		let mymacro = macro(x, y) { x + y; };
+	`
+	program := parseProgram(t, input)
+	environment := object.NewEnvironment()
+	DefineMacros(program, environment)
+
+	assert.Len(t, program.Statements, 2)
+
+	_, ok := environment.Get("number")
+	assert.False(t, ok)
+	_, ok = environment.Get("function")
+	assert.False(t, ok)
+
+	value, ok := environment.Get("mymacro")
+	assert.True(t, ok)
+	macro, ok := value.(*object.Macro)
+	if assert.True(t, ok) {
+		assert.Len(t, macro.Parameters, 2)
+		assert.Equal(t, "(x + y);", macro.Body.PrettyPrint())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let infixExpression = macro() { quote(1 + 2) }; infixExpression();",
+			"(1 + 2)",
+		},
+		{
+			"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5);",
+			"((10 - 5) - (2 + 2))",
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			"if ((!(10 > 5))) {puts(not greater);} else {puts(greater);}",
+		},
+		{
+			"let twice = macro(x) { quote([unquote(x), unquote(x)]) }; let f = fn() { twice(1 + 1) };",
+			"let f = fn() { [(1 + 1), (1 + 1)]; };",
+		},
+	}
+
+	for _, test := range tests {
+		program := parseProgram(t, test.input)
+		environment := object.NewEnvironment()
+		DefineMacros(program, environment)
+
+		expanded, errorObject := ExpandMacros(program, environment)
+		if assert.Nil(t, errorObject, test.input) {
+			assert.Equal(t, test.expected, expanded.PrettyPrint(), test.input)
+		}
+	}
+}
+
+func TestExpandMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(x) { 1 }; m(1)", "macro m must return a quote, got INTEGER"},
+		{"let m = macro(x) { quote(x) }; m(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"let m = macro(x) { unquote(x) + 1 }; m(1)", "identifier not found: unquote"},
+		{"let m = fn() { macro(x) { x } }; m()", ""},
+	}
+
+	for _, test := range tests {
+		program := parseProgram(t, test.input)
+		environment := object.NewEnvironment()
+		DefineMacros(program, environment)
+
+		_, errorObject := ExpandMacros(program, environment)
+		if test.expected == "" {
+			assert.Nil(t, errorObject, test.input)
+		} else if assert.NotNil(t, errorObject, test.input) {
+			assert.Equal(t, test.expected, errorObject.Message, test.input)
+		}
+	}
+
+	evaluated := eval(t, "let m = fn() { macro(x) { x } }; m()")
+	assertErrorObject(t, evaluated, "macros can only be defined by top level let statements")
+}
@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/object"
+)
+
+// Creates the error raised by throwing the value, shared with the vm. Throwing a caught exception
+// raises its error again, keeping where it originally occurred
+func Throw(value object.Object) *object.Error {
+	if exception, ok := value.(*object.Exception); ok {
+		return exception.Error
+	}
+
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+
+	return &object.Error{Message: message, Payload: value}
+}
+
+func evalTryExpression(node *ast.TryExpression, environment *object.Environment) object.Object {
+	result := Eval(node.Body, environment)
+
+	errorObject, ok := result.(*object.Error)
+	if ok && !errorObject.Catchable() {
+		return result
+	}
+
+	if ok && node.CatchBlock != nil {
+		exception := &object.Exception{Error: errorObject}
+		result = Eval(node.CatchBlock, object.NewBlockEnvironment(environment, node.CatchParameter.Value, exception))
+	}
+
+	if node.FinallyBlock != nil {
+		// Leaving the finally block early replaces the result of the try, including any error
+		switch finally := Eval(node.FinallyBlock, environment); finally.Type() {
+		case object.RETURN_VALUE, object.ERROR, object.BREAK, object.CONTINUE:
+			return finally
+		}
+	}
+
+	return result
+}
+
+// Accesses the members of a caught exception, shared with the vm
+func evalExceptionMember(exception *object.Exception, name string) object.Object {
+	switch name {
+	case "message":
+		return &object.String{Value: exception.Error.Message}
+	case "payload":
+		// Errors raised by the interpreter carry their message
+		if exception.Error.Payload == nil {
+			return &object.String{Value: exception.Error.Message}
+		}
+		return exception.Error.Payload
+	case "stack":
+		frames := make([]object.Object, len(exception.Error.Stack))
+		for i, frame := range exception.Error.Stack {
+			frames[i] = &object.String{Value: frame.String()}
+		}
+		return &object.Array{Elements: frames}
+	default:
+		return newError("exception has no member %s", name)
+	}
+}
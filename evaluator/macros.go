@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/object"
+)
+
+// Removes the top level `let name = macro(...) { ... }` statements from the program, defining each
+// macro within the given environment ready for ExpandMacros
+func DefineMacros(program *ast.Program, environment *object.Environment) {
+	var statements []ast.Statement
+
+	for _, statement := range program.Statements {
+		letStatement, ok := statement.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+
+		macroLiteral, ok := letStatement.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+
+		macro := &object.Macro{
+			Parameters:  macroLiteral.Parameters,
+			Body:        macroLiteral.Body,
+			Environment: environment,
+		}
+		environment.Add(letStatement.Name.Value, macro)
+	}
+
+	program.Statements = statements
+}
+
+// Returns a copy of the program where each call to a macro defined within the environment has been
+// replaced by the quoted AST node returned from the macro's body. The arguments are passed to the
+// macro unevaluated, as quotes
+func ExpandMacros(program *ast.Program, environment *object.Environment) (*ast.Program, *object.Error) {
+	var errorObject *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || errorObject != nil {
+			return node
+		}
+
+		identifier, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+
+		value, ok := environment.Get(identifier.Value)
+		if !ok {
+			return node
+		}
+		macro, ok := value.(*object.Macro)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			errorObject = newError("wrong number of arguments. got=%d, want=%d", len(call.Arguments), len(macro.Parameters))
+			errorObject.Pos = call.Pos()
+			return node
+		}
+
+		macroEnvironment := object.NewClosedEnvironment(macro.Environment)
+		for index, parameter := range macro.Parameters {
+			macroEnvironment.Add(parameter.Value, &object.Quote{Node: call.Arguments[index]})
+		}
+
+		evaluated := unwrapResult(Eval(macro.Body, macroEnvironment))
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node
+		case *object.Error:
+			evaluated.Stack = append(evaluated.Stack, object.StackFrame{Function: identifier.Value, CallSite: call.Pos()})
+			errorObject = evaluated
+		default:
+			errorObject = newError("macro %s must return a quote, got %s", identifier.Value, evaluated.Type())
+			errorObject.Pos = call.Pos()
+		}
+
+		return node
+	})
+
+	if errorObject != nil {
+		return nil, errorObject
+	}
+
+	return expanded.(*ast.Program), nil
+}
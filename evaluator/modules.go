@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/lexer"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/parser"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Runs the program of an imported module, returning its exported bindings or the error which
+// stopped it. This allows the evaluator and the vm to share the loading and caching of modules
+type ModuleRunner func(program *ast.Program) (map[string]object.Object, object.Object)
+
+// Import paths are relative to the directory of the file containing the import, or the working
+// directory when there is no file such as within the REPL
+func ResolveImportPath(path string, importer string) string {
+	if filepath.IsAbs(path) || importer == "" {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(filepath.Dir(importer), path)
+}
+
+// Top level bindings are exported, unless their name starts with an underscore
+func IsExported(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
+
+// Loads the module at the already resolved path. Each module is evaluated once, after which the
+// cached module is returned
+func ImportModule(modules *object.ModuleRegistry, path string, run ModuleRunner) object.Object {
+	if module, ok := modules.Get(path); ok {
+		return module
+	}
+
+	if cycle, ok := modules.StartLoading(path); !ok {
+		return newError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+	defer modules.FinishLoading(path)
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newError("could not import %q: %s", path, err)
+	}
+
+	p := parser.New(lexer.NewFile(path, string(source)))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		var messages []string
+		for _, err := range p.ErrorList() {
+			messages = append(messages, err.Error())
+		}
+		return newError("could not import %q: %s", path, strings.Join(messages, "; "))
+	}
+
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	program, expansionError := ExpandMacros(program, macros)
+	if expansionError != nil {
+		return expansionError
+	}
+
+	exports, errorObject := run(program)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	module := &object.Module{Name: path, Exports: exports}
+	modules.Add(module)
+
+	return module
+}
+
+func evalImportExpression(node *ast.ImportExpression, environment *object.Environment) object.Object {
+	path := ResolveImportPath(node.Path.Value, node.Pos().Filename)
+
+	return ImportModule(environment.Modules(), path, func(program *ast.Program) (map[string]object.Object, object.Object) {
+		moduleEnvironment := object.NewModuleEnvironment(environment.Modules(), environment.Builtins())
+		moduleEnvironment.SetLimits(environment.Limits())
+
+		result := Eval(program, moduleEnvironment)
+		if isError(result) {
+			return nil, result
+		}
+
+		exports := make(map[string]object.Object)
+		for _, name := range moduleEnvironment.Names() {
+			if IsExported(name) {
+				exports[name], _ = moduleEnvironment.Get(name)
+			}
+		}
+
+		return exports, nil
+	})
+}
@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/token"
+)
+
+// Calls to quote receive their argument unevaluated, so they are handled before normal calls. The
+// caller must check that quote is not shadowed by a binding of the same name
+func IsQuoteCall(node *ast.CallExpression) bool {
+	identifier, ok := node.Function.(*ast.Identifier)
+	return ok && identifier.Value == "quote"
+}
+
+func isQuoteCall(node *ast.CallExpression, environment *object.Environment) bool {
+	if !IsQuoteCall(node) {
+		return false
+	}
+
+	_, shadowed := environment.Get("quote")
+	return !shadowed
+}
+
+func IsUnquoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	identifier, ok := call.Function.(*ast.Identifier)
+	return ok && identifier.Value == "unquote"
+}
+
+func evalQuoteCall(node *ast.CallExpression, environment *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(node.Arguments))
+	}
+
+	return quote(node.Arguments[0], environment)
+}
+
+// Wraps the node within a Quote without evaluating it. The exception is any call to unquote
+// within the node, which is evaluated and its result converted back into an AST node
+func quote(node ast.Node, environment *object.Environment) object.Object {
+	var errorObject object.Object
+
+	quoted := ast.Modify(node, func(node ast.Node) ast.Node {
+		if errorObject != nil || !IsUnquoteCall(node) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err := newError("wrong number of arguments. got=%d, want=1", len(call.Arguments))
+			err.Pos = call.Pos()
+			errorObject = err
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], environment)
+		if isError(unquoted) {
+			errorObject = unquoted
+			return node
+		}
+
+		replacement, err := unquoteNode(unquoted, call)
+		if err != nil {
+			errorObject = err
+			return node
+		}
+
+		return replacement
+	})
+
+	if errorObject != nil {
+		return errorObject
+	}
+
+	return &object.Quote{Node: quoted}
+}
+
+// Quotes the node with its calls to unquote replaced by the given values, which are the already
+// evaluated arguments of the calls in the order ast.Modify visits them. Shared with the vm
+func QuoteWithValues(node ast.Node, values []object.Object) object.Object {
+	var errorObject *object.Error
+	index := 0
+
+	quoted := ast.Modify(node, func(node ast.Node) ast.Node {
+		if errorObject != nil || !IsUnquoteCall(node) || index >= len(values) {
+			return node
+		}
+
+		replacement, err := unquoteNode(values[index], node.(*ast.CallExpression))
+		index++
+		if err != nil {
+			errorObject = err
+			return node
+		}
+
+		return replacement
+	})
+
+	if errorObject != nil {
+		return errorObject
+	}
+
+	return &object.Quote{Node: quoted}
+}
+
+func unquoteNode(unquoted object.Object, call *ast.CallExpression) (ast.Node, *object.Error) {
+	replacement := objectToNode(unquoted, call)
+	if replacement == nil {
+		err := newError("cannot unquote %s", unquoted.Type())
+		err.Pos = call.Pos()
+		return nil, err
+	}
+
+	return replacement, nil
+}
+
+// Converts the result of unquote back into an AST node, positioned at the call to unquote. Returns
+// nil for objects which have no literal syntax
+func objectToNode(o object.Object, call *ast.CallExpression) ast.Node {
+	newToken := func(tokenType token.TokenType, literal string) token.Token {
+		return token.Token{Type: tokenType, Literal: literal, Pos: call.Pos(), End: call.End()}
+	}
+
+	switch o := o.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: newToken(token.INT, o.Inspect()), Value: o.Value}
+	case *object.BigInt:
+		return &ast.BigIntLiteral{Token: newToken(token.BIGINT, o.Inspect()), Value: o.Value}
+	case *object.Float:
+		return &ast.FloatLiteral{Token: newToken(token.FLOAT, o.Inspect()), Value: o.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: newToken(token.STRING, o.Value), Value: o.Value}
+	case *object.Boolean:
+		if o.Value {
+			return &ast.Boolean{Token: newToken(token.TRUE, "true"), Value: true}
+		}
+		return &ast.Boolean{Token: newToken(token.FALSE, "false"), Value: false}
+	case *object.Quote:
+		return o.Node
+	}
+
+	return nil
+}
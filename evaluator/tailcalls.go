@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/token"
+)
+
+// Calls the function with arguments of the expected arity. Calls made in tail position are returned
+// by the function body rather than made, and are then made in turn here. This trampoline means
+// recursion in tail position runs in constant stack space however deep it goes
+func callFunction(fn *object.Function, args []object.Object, callSite token.Position) object.Object {
+	first := object.StackFrame{Function: fn.Name, CallSite: callSite}
+	current := first
+	tailCalls := 0
+
+	for {
+		evaluated := unwrapResult(evalTail(fn.Body, extendFunctionEnvironment(fn, args)))
+
+		if tailCall, ok := evaluated.(*object.TailCall); ok {
+			fn, args = tailCall.Function, tailCall.Arguments
+			current = object.StackFrame{Function: fn.Name, CallSite: tailCall.CallSite}
+			tailCalls++
+			continue
+		}
+
+		// Record each function call the error propagates through, building up the stack trace. Only
+		// the first and last calls of a chain of tail calls are still known
+		if errorObject, ok := evaluated.(*object.Error); ok {
+			errorObject.Stack = append(errorObject.Stack, current)
+			if tailCalls > 1 {
+				errorObject.Stack = append(errorObject.Stack, object.StackFrame{Omitted: tailCalls - 1})
+			}
+			if tailCalls > 0 {
+				errorObject.Stack = append(errorObject.Stack, first)
+			}
+		}
+
+		return evaluated
+	}
+}
+
+// Evaluates a node in tail position of a function body, which is its last statement, the value of a
+// return statement, or the branches of an if expression in tail position. Calls to functions in
+// tail position are returned as a TailCall for callFunction to make
+func evalTail(node ast.Node, environment *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for index, statement := range node.Statements {
+			result := evalTail(statement, environment)
+			if index == len(node.Statements)-1 {
+				return result
+			}
+
+			// Only calls which are returned are in tail position before the last statement
+			if tailCall, ok := result.(*object.TailCall); ok {
+				result = applyFunction(tailCall.Function, tailCall.Arguments, tailCall.CallSite)
+				if errorObject, ok := result.(*object.Error); ok && !errorObject.Pos.IsValid() {
+					errorObject.Pos = tailCall.CallSite
+				}
+			}
+
+			if rt := result.Type(); rt == object.RETURN_VALUE || rt == object.ERROR || rt == object.BREAK || rt == object.CONTINUE {
+				return result
+			}
+		}
+		return NULL
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, environment)
+	case *ast.ReturnStatement:
+		value := evalTail(node.Value, environment)
+		if isError(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *ast.IfExpression:
+		predicate := Eval(node.Predicate, environment)
+		if isError(predicate) {
+			return predicate
+		}
+
+		if IsTruthy(predicate) {
+			return evalTail(node.TrueBlock, environment)
+		} else if node.FalseBlock != nil {
+			return evalTail(node.FalseBlock, environment)
+		}
+		return NULL
+	case *ast.CallExpression:
+		if !isQuoteCall(node, environment) {
+			return evalTailCall(node, environment)
+		}
+	}
+
+	return Eval(node, environment)
+}
+
+func evalTailCall(node *ast.CallExpression, environment *object.Environment) object.Object {
+	if errorObject := environment.Limits().Step(); errorObject != nil {
+		errorObject.Pos = node.Pos()
+		return errorObject
+	}
+
+	function := Eval(node.Function, environment)
+	if isError(function) {
+		return function
+	}
+
+	args, errorObject := evalExpressions(node.Arguments, environment)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	fn, ok := function.(*object.Function)
+	if !ok || len(args) != len(fn.Parameters) {
+		result := applyFunction(function, args, node.Pos())
+		if errorObject, ok := result.(*object.Error); ok && !errorObject.Pos.IsValid() {
+			errorObject.Pos = node.Pos()
+		}
+		return result
+	}
+
+	return &object.TailCall{Function: fn, Arguments: args, CallSite: node.Pos()}
+}
@@ -2,23 +2,42 @@ package lexer
 
 import (
 	"github.com/alanfoster/monkey/token"
+	"unicode/utf8"
 )
 
 type Lexer struct {
+	filename     string
 	input        string
 	position     int  // Current position in input (points to current char)
 	readPosition int  // Current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // Line of the current char
+	column       int  // Column of the current char
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// Creates a lexer whose token positions will reference the given filename
+func NewFile(filename string, input string) *Lexer {
+	lexer := &Lexer{filename: filename, input: input, line: 1}
 	lexer.readChar()
 
 	return lexer;
 }
 
 func (l *Lexer) readChar() {
+	// Once the end of input has been reached the position no longer advances
+	if l.readPosition > len(l.input) {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // Ascii code for the 'NUL' character
 	} else {
@@ -26,11 +45,26 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+
+	// Columns count characters rather than bytes, so the continuation bytes of a multi-byte UTF-8
+	// character do not move the column on
+	if !utf8.RuneStart(l.ch) {
+		return
+	}
+	l.column += 1
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+// The position of the current char under examination
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
 
+func (l *Lexer) NextToken() token.Token {
 	for {
 		l.skipWhitespace()
 		hasSkippedComments := l.skipComments()
@@ -39,6 +73,17 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	start := l.currentPosition()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.currentPosition()
+
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -47,9 +92,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newCharToken(token.EQ, l.ch)
 		}
 	case '+':
-		tok = newCharToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.PLUS_EQ, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newCharToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.MINUS_EQ, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			tok = newStringToken(token.NOT_EQ, l.readTwoCharacterLiteral())
@@ -57,17 +110,61 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newCharToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newCharToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.ASTERISK_EQ, l.readTwoCharacterLiteral())
+		} else if l.peekChar() == '*' {
+			tok = newStringToken(token.POWER, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.ASTERISK, l.ch)
+		}
 	case '/':
-		tok = newCharToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.SLASH_EQ, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.SLASH, l.ch)
+		}
+	case '%':
+		tok = newCharToken(token.PERCENT, l.ch)
+	case '&':
+		if l.peekChar() == '&' {
+			tok = newStringToken(token.AND, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = newStringToken(token.OR, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		tok = newCharToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newCharToken(token.BIT_NOT, l.ch)
 	case '<':
-		tok = newCharToken(token.LESS_THAN, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.LESS_THAN_EQ, l.readTwoCharacterLiteral())
+		} else if l.peekChar() == '<' {
+			tok = newStringToken(token.SHIFT_LEFT, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.LESS_THAN, l.ch)
+		}
 	case '>':
-		tok = newCharToken(token.GREATER_THAN, l.ch)
+		if l.peekChar() == '=' {
+			tok = newStringToken(token.GREATER_THAN_EQ, l.readTwoCharacterLiteral())
+		} else if l.peekChar() == '>' {
+			tok = newStringToken(token.SHIFT_RIGHT, l.readTwoCharacterLiteral())
+		} else {
+			tok = newCharToken(token.GREATER_THAN, l.ch)
+		}
 	case ',':
 		tok = newCharToken(token.COMMA, l.ch)
 	case ';':
 		tok = newCharToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newCharToken(token.COLON, l.ch)
+	case '.':
+		tok = newCharToken(token.DOT, l.ch)
 	case '(':
 		tok = newCharToken(token.LEFT_PAREN, l.ch)
 	case ')':
@@ -90,7 +187,7 @@ func (l *Lexer) NextToken() token.Token {
 			tokenType := token.LookupIdentifier(literal)
 			return newStringToken(tokenType, literal)
 		} else if isDigit(l.ch) {
-			return newStringToken(token.INT, l.readNumber())
+			return newStringToken(l.readNumber())
 		} else {
 			tok = newCharToken(token.ILLEGAL, l.ch)
 		}
@@ -110,14 +207,44 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// Reads a number from the input string. This will update the position of the
-// lexer internally a appropriate
-func (l *Lexer) readNumber() string {
+// Reads an integer or float literal from the input string. Floats have a fractional part, an
+// exponent, or both, i.e. 3.14, 1e9 or 2.5E-3. Integers suffixed with n are big integers, i.e. 10n
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekCharAt(1)) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekCharAt(1)
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	if tokenType == token.INT && l.ch == 'n' {
+		tokenType = token.BIGINT
+		l.readChar()
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 // Reads a two character literal from the input string. Useful for two character operators
@@ -163,7 +290,16 @@ func (l *Lexer) skipComments() bool {
 // Peek at the next character within the input stream without updating the position
 // of the lexer internally
 func (l *Lexer) peekChar() byte {
-	return l.input[l.position+1]
+	return l.peekCharAt(1)
+}
+
+// Returns the char the given distance ahead of the current char, without consuming any input
+func (l *Lexer) peekCharAt(distance int) byte {
+	position := l.position + distance
+	if position >= len(l.input) {
+		return 0
+	}
+	return l.input[position]
 }
 
 func newCharToken(tokenType token.TokenType, ch byte) token.Token {
@@ -16,10 +16,18 @@ func TestBasicPunctuation(t *testing.T) {
 		!=
 		*
 		/
+		+=
+		-=
+		*=
+		/=
 		<
 		>
+		&&
+		||
 		,
 		;
+		:
+		.
 		()
 		{}
 		[]
@@ -37,10 +45,18 @@ func TestBasicPunctuation(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.ASTERISK, "*"},
 		{token.SLASH, "/"},
+		{token.PLUS_EQ, "+="},
+		{token.MINUS_EQ, "-="},
+		{token.ASTERISK_EQ, "*="},
+		{token.SLASH_EQ, "/="},
 		{token.LESS_THAN, "<"},
 		{token.GREATER_THAN, ">"},
+		{token.AND, "&&"},
+		{token.OR, "||"},
 		{token.COMMA, ","},
 		{token.SEMICOLON, ";"},
+		{token.COLON, ":"},
+		{token.DOT, "."},
 		{token.LEFT_PAREN, "("},
 		{token.RIGHT_PAREN, ")"},
 		{token.LEFT_BRACE, "{"},
@@ -203,3 +219,170 @@ func TestBranching(t *testing.T) {
 		assert.Equal(t, expected.Literal, tok.Literal)
 	}
 }
+
+func TestPositions(t *testing.T) {
+	input := "let x = 5;\n  \"hi\" // comment\nfoo(x)"
+
+	expectedTokens := []struct {
+		Type  token.TokenType
+		Start string
+		End   string
+	}{
+		{token.LET, "1:1", "1:4"},
+		{token.IDENTIFIER, "1:5", "1:6"},
+		{token.EQ, "1:7", "1:8"},
+		{token.INT, "1:9", "1:10"},
+		{token.SEMICOLON, "1:10", "1:11"},
+		{token.STRING, "2:3", "2:7"},
+		{token.IDENTIFIER, "3:1", "3:4"},
+		{token.LEFT_PAREN, "3:4", "3:5"},
+		{token.IDENTIFIER, "3:5", "3:6"},
+		{token.RIGHT_PAREN, "3:6", "3:7"},
+		{token.EOF, "3:7", "3:7"},
+		{token.EOF, "3:7", "3:7"},
+	}
+
+	l := New(input)
+
+	for _, expected := range expectedTokens {
+		tok := l.NextToken()
+
+		assert.Equal(t, expected.Type, tok.Type)
+		assert.Equal(t, expected.Start, tok.Pos.String())
+		assert.Equal(t, expected.End, tok.End.String())
+	}
+}
+
+func TestPositionsCountCharacters(t *testing.T) {
+	l := New(`"héllo" + 1`)
+
+	tok := l.NextToken()
+	assert.Equal(t, "1:1", tok.Pos.String())
+	assert.Equal(t, "1:8", tok.End.String())
+
+	tok = l.NextToken()
+	assert.Equal(t, "+", tok.Literal)
+	assert.Equal(t, token.Position{Offset: 9, Line: 1, Column: 9}, tok.Pos)
+}
+
+func TestFilePositions(t *testing.T) {
+	l := NewFile("example.monkey", "\n\n  1337")
+
+	tok := l.NextToken()
+	assert.Equal(t, token.Position{Filename: "example.monkey", Offset: 4, Line: 3, Column: 3}, tok.Pos)
+	assert.Equal(t, "example.monkey:3:3", tok.Pos.String())
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `
+		while (true) { break; }
+		for (x in xs) { continue; }
+	`
+
+	expectedTokens := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.WHILE, "while"},
+		{token.LEFT_PAREN, "("},
+		{token.TRUE, "true"},
+		{token.RIGHT_PAREN, ")"},
+		{token.LEFT_BRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.RIGHT_BRACE, "}"},
+
+		{token.FOR, "for"},
+		{token.LEFT_PAREN, "("},
+		{token.IDENTIFIER, "x"},
+		{token.IN, "in"},
+		{token.IDENTIFIER, "xs"},
+		{token.RIGHT_PAREN, ")"},
+		{token.LEFT_BRACE, "{"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RIGHT_BRACE, "}"},
+
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, expected := range expectedTokens {
+		tok := l.NextToken()
+
+		assert.Equal(t, expected.Type, tok.Type)
+		assert.Equal(t, expected.Literal, tok.Literal)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `<= >= < > << >> % ** * & && | || ^ ~`
+
+	expectedTokens := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.LESS_THAN_EQ, "<="},
+		{token.GREATER_THAN_EQ, ">="},
+		{token.LESS_THAN, "<"},
+		{token.GREATER_THAN, ">"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.BIT_AND, "&"},
+		{token.AND, "&&"},
+		{token.BIT_OR, "|"},
+		{token.OR, "||"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, expected := range expectedTokens {
+		tok := l.NextToken()
+
+		assert.Equal(t, expected.Type, tok.Type)
+		assert.Equal(t, expected.Literal, tok.Literal)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 3.14 1e9 2.5E-3 1e+2 1.x 1e x 7. 10n 1.5n`
+
+	expectedTokens := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "1e+2"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "x"},
+		{token.INT, "1"},
+		{token.IDENTIFIER, "e"},
+		{token.IDENTIFIER, "x"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.BIGINT, "10n"},
+		{token.FLOAT, "1.5"},
+		{token.IDENTIFIER, "n"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, expected := range expectedTokens {
+		tok := l.NextToken()
+
+		assert.Equal(t, expected.Type, tok.Type)
+		assert.Equal(t, expected.Literal, tok.Literal)
+	}
+}
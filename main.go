@@ -5,51 +5,161 @@ import (
 	"github.com/alanfoster/monkey/repl"
 	"os"
 	"io"
+	"context"
 	"io/ioutil"
 	"github.com/alanfoster/monkey/lexer"
 	"github.com/alanfoster/monkey/evaluator"
 	"fmt"
 	"github.com/alanfoster/monkey/parser"
 	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/compiler"
+	"github.com/alanfoster/monkey/token"
+	"github.com/alanfoster/monkey/vm"
 )
 
-func printParsingErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Error: Parsing errors found.")
+const (
+	EVAL_ENGINE = "eval"
+	VM_ENGINE   = "vm"
+)
+
+const (
+	EXIT_OK = 0
+	// The entry file could not be read, parsed or compiled
+	EXIT_STATIC_ERROR = 1
+	// The program stopped with an error while running
+	EXIT_RUNTIME_ERROR = 2
+)
+
+func printParsingErrors(out io.Writer, errors []*parser.Error, source string) {
+	io.WriteString(out, "Error: Parsing errors found.\n")
 	for _, e := range errors {
-		fmt.Fprintf(out, "%v\n", e)
+		fmt.Fprintln(out, token.FormatError(e.Pos, e.Message, source))
 	}
 }
 
-func interpretFile(path string, out io.Writer) {
+func printRuntimeError(out io.Writer, errorObject *object.Error, path string, source string) {
+	if !errorObject.Pos.IsValid() {
+		fmt.Fprintln(out, errorObject.Inspect())
+	} else {
+		// Errors may have occurred within an imported module rather than the entry file
+		if errorObject.Pos.Filename != path {
+			data, _ := ioutil.ReadFile(errorObject.Pos.Filename)
+			source = string(data)
+		}
+		fmt.Fprintln(out, token.FormatError(errorObject.Pos, errorObject.Message, source))
+	}
+
+	io.WriteString(out, errorObject.StackTrace())
+}
+
+// Runs the file, returning the exit code of the process
+func interpretFile(path string, engine string, limits *object.Limits, stdout io.Writer, stderr io.Writer) int {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Println("oops")
-		panic(err)
+		fmt.Fprintf(stderr, "Error: Could not read entry file: %s\n", err)
+		return EXIT_STATIC_ERROR
 	}
 
-	l := lexer.New(string(data))
+	source := string(data)
+	l := lexer.NewFile(path, source)
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	errors := p.Errors()
+	errors := p.ErrorList()
 
 	if len(errors) != 0 {
-		printParsingErrors(out, errors)
-		return
+		printParsingErrors(stderr, errors, source)
+		return EXIT_STATIC_ERROR
 	}
 
-	environment := object.NewEnvironment()
-	evaluator.Eval(program, environment)
+	builtins := evaluator.NewBuiltinRegistryWithStreams(&object.Streams{Stdin: os.Stdin, Stdout: stdout, Stderr: stderr})
+
+	// Macros are expanded within the same limits and with the same builtins as the program itself
+	limits.Start(context.Background())
+	macros := object.NewEnvironmentWithBuiltins(builtins)
+	macros.SetLimits(limits)
+	evaluator.DefineMacros(program, macros)
+	program, errorObject := evaluator.ExpandMacros(program, macros)
+	if errorObject != nil {
+		printRuntimeError(stderr, errorObject, path, source)
+		return EXIT_STATIC_ERROR
+	}
+
+	if engine == VM_ENGINE {
+		errorObject, err = runVM(program, builtins, limits)
+	} else {
+		errorObject = runEvaluator(program, builtins, limits)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: Compilation failed: %s\n", err)
+		return EXIT_STATIC_ERROR
+	}
+
+	if errorObject != nil {
+		if errorObject.Exited {
+			return errorObject.ExitCode
+		}
+
+		printRuntimeError(stderr, errorObject, path, source)
+		return EXIT_RUNTIME_ERROR
+	}
+
+	return EXIT_OK
+}
+
+// Runs the program, returning the error which stopped it if any
+func runEvaluator(program *ast.Program, builtins *object.BuiltinRegistry, limits *object.Limits) *object.Error {
+	environment := object.NewEnvironmentWithBuiltins(builtins)
+	environment.SetLimits(limits)
+	result := evaluator.Eval(program, environment)
+
+	if errorObject, ok := result.(*object.Error); ok {
+		return errorObject
+	}
+	return nil
+}
+
+// Compiles and runs the program, returning the error which stopped it if any
+func runVM(program *ast.Program, builtins *object.BuiltinRegistry, limits *object.Limits) (*object.Error, error) {
+	c := compiler.NewWithBuiltins(builtins)
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.NewWithBuiltins(c.Bytecode(), builtins)
+	machine.SetLimits(limits)
+	if err := machine.Run(); err != nil {
+		if runtimeError, ok := err.(*vm.RuntimeError); ok {
+			return runtimeError.Object, nil
+		}
+		return &object.Error{Message: err.Error()}, nil
+	}
+
+	return nil, nil
 }
 
 func main() {
 	var entryFile string
+	var engine string
+	limits := &object.Limits{}
 	flag.StringVar(&entryFile, "entry-file", "", "File to run as a monkey file program")
+	flag.StringVar(&engine, "engine", EVAL_ENGINE, "The engine used to run the entry file, either eval or vm")
+	flag.Int64Var(&limits.MaxSteps, "max-steps", 0, "The maximum number of steps a program may run for, zero for no limit")
+	flag.IntVar(&limits.MaxDepth, "max-depth", object.DefaultMaxDepth, "The maximum depth of nested function calls")
+	flag.BoolVar(&limits.CheckOverflow, "check-overflow", false, "Report integer overflow as an error rather than promoting the result to a big integer")
+	flag.DurationVar(&limits.Timeout, "timeout", 0, "The maximum duration a program may run for, for example 5s, zero for no limit")
 	flag.Parse()
 
+	if engine != EVAL_ENGINE && engine != VM_ENGINE {
+		fmt.Fprintf(os.Stderr, "Unknown engine %q, expected %s or %s\n", engine, EVAL_ENGINE, VM_ENGINE)
+		os.Exit(1)
+	}
+
 	if entryFile != "" {
-		interpretFile(entryFile, os.Stdout)
+		os.Exit(interpretFile(entryFile, engine, limits, os.Stdout, os.Stderr))
 	} else {
-		repl.Start(os.Stdin, os.Stdout)
+		repl.Start(os.Stdin, os.Stdout, limits)
 	}
 }
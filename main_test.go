@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"github.com/alanfoster/monkey/object"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpretFile(t *testing.T) {
+	tests := []struct {
+		source string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{`puts("hello")`, EXIT_OK, "hello\n", ""},
+		{"let x = ;", EXIT_STATIC_ERROR, "", "Error: Parsing errors found.\n"},
+		{"puts(1); 1 + true", EXIT_RUNTIME_ERROR, "1\n", ":1:10: type mismatch: INTEGER + BOOLEAN\n"},
+		{`let s = "é"; s + 1`, EXIT_RUNTIME_ERROR, "", ":1:14: type mismatch: STRING + INTEGER\nlet s = \"é\"; s + 1\n             ^\n"},
+		{"let f = fn(x) {\n\tx + true\n};\nf(1)", EXIT_RUNTIME_ERROR, "", ":2:2: type mismatch: INTEGER + BOOLEAN\n\tx + true\n\t^\n    in f, called at "},
+		{"puts(1); exit(3); puts(2)", 3, "1\n", ""},
+		{"exit()", EXIT_OK, "", ""},
+		{`let m = macro() { puts("expanding"); quote(1) }; puts(m())`, EXIT_OK, "expanding\n1\n", ""},
+	}
+
+	directory, err := ioutil.TempDir("", "monkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "main.monkey")
+
+	for _, engine := range []string{EVAL_ENGINE, VM_ENGINE} {
+		for _, tt := range tests {
+			assert.NoError(t, ioutil.WriteFile(path, []byte(tt.source), 0644))
+
+			var stdout, stderr bytes.Buffer
+			code := interpretFile(path, engine, &object.Limits{}, &stdout, &stderr)
+
+			assert.Equal(t, tt.code, code, tt.source)
+			assert.Equal(t, tt.stdout, stdout.String(), tt.source)
+			assert.Contains(t, stderr.String(), tt.stderr, tt.source)
+		}
+	}
+}
+
+func TestInterpretFileLimitsMacros(t *testing.T) {
+	directory, err := ioutil.TempDir("", "monkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "main.monkey")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("let m = macro() { while (true) { } }; m()"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := interpretFile(path, EVAL_ENGINE, &object.Limits{MaxSteps: 1000}, &stdout, &stderr)
+
+	assert.Equal(t, EXIT_STATIC_ERROR, code)
+	assert.Contains(t, stderr.String(), "execution limit exceeded")
+}
+
+func TestInterpretFileThatCannotBeRead(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := interpretFile("missing.monkey", EVAL_ENGINE, &object.Limits{}, &stdout, &stderr)
+
+	assert.Equal(t, EXIT_STATIC_ERROR, code)
+	assert.Equal(t, "Error: Could not read entry file: open missing.monkey: no such file or directory\n", stderr.String())
+}
@@ -0,0 +1,188 @@
+package monkey
+
+import (
+	"fmt"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/object"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// Converts a Go value into its monkey equivalent:
+//
+//	nil                     null
+//	bool                    BOOLEAN
+//	int, int8 ... uint64    INTEGER
+//	*big.Int                BIGINT
+//	float32, float64        FLOAT
+//	string                  STRING
+//	slices and arrays       ARRAY
+//	maps                    HASH, the keys must convert to an INTEGER, BOOLEAN or STRING
+//
+// Pointers are followed, and values which are already an object.Object are returned as they are
+func ToObject(value interface{}) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+
+	if o, ok := value.(object.Object); ok {
+		return o, nil
+	}
+
+	if integer, ok := value.(*big.Int); ok {
+		return &object.BigInt{Value: new(big.Int).Set(integer)}, nil
+	}
+
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(value reflect.Value) (object.Object, error) {
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: value.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER, it is out of range", value.Uint())
+		}
+		return &object.Integer{Value: int64(value.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: value.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: value.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return evaluator.NULL, nil
+		}
+
+		elements := make([]object.Object, value.Len())
+		for i := range elements {
+			element, err := toObject(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+
+		hash := object.NewHash()
+		for _, key := range sortedMapKeys(value) {
+			keyObject, err := toObject(key)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := keyObject.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", keyObject.Type())
+			}
+
+			valueObject, err := toObject(value.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashable, valueObject)
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if o, ok := value.Interface().(object.Object); ok {
+			return o, nil
+		}
+		return toObject(value.Elem())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a monkey value", value.Type())
+}
+
+// Go maps are unordered, so the keys are sorted to give hashes a stable order
+func sortedMapKeys(value reflect.Value) []reflect.Value {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// Converts a monkey value into its Go equivalent:
+//
+//	null       nil
+//	BOOLEAN    bool
+//	INTEGER    int64
+//	BIGINT     *big.Int
+//	FLOAT      float64
+//	STRING     string
+//	ARRAY      []interface{}
+//	HASH       map[interface{}]interface{}
+//
+// Values without a Go equivalent, such as functions, are returned as they are. An array or hash
+// which contains itself has no Go equivalent and is an error
+func FromObject(o object.Object) (interface{}, error) {
+	return fromObject(o, map[object.Object]bool{})
+}
+
+func fromObject(o object.Object, converting map[object.Object]bool) (interface{}, error) {
+	switch o := o.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return o.Value, nil
+	case *object.Integer:
+		return o.Value, nil
+	case *object.BigInt:
+		return new(big.Int).Set(o.Value), nil
+	case *object.Float:
+		return o.Value, nil
+	case *object.String:
+		return o.Value, nil
+	case *object.Array:
+		if converting[o] {
+			return nil, fmt.Errorf("cannot convert ARRAY to a Go value, it contains itself")
+		}
+		converting[o] = true
+		defer delete(converting, o)
+
+		elements := make([]interface{}, len(o.Elements))
+		for i, element := range o.Elements {
+			converted, err := fromObject(element, converting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = converted
+		}
+		return elements, nil
+	case *object.Hash:
+		if converting[o] {
+			return nil, fmt.Errorf("cannot convert HASH to a Go value, it contains itself")
+		}
+		converting[o] = true
+		defer delete(converting, o)
+
+		pairs := make(map[interface{}]interface{}, len(o.Pairs))
+		for _, pair := range o.Entries() {
+			key, err := fromObject(pair.Key, converting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := fromObject(pair.Value, converting)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key] = value
+		}
+		return pairs, nil
+	}
+
+	return o, nil
+}
@@ -0,0 +1,228 @@
+package monkey
+
+import (
+	"fmt"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/object"
+	"math/big"
+	"reflect"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Wraps a Go function as a builtin, such as `func(n int64, s string) (bool, error)`. Arguments are
+// checked and converted to the parameter types of the function, and the results are converted
+// with ToObject. The function may return nothing, a single value, an error, or a value and an
+// error. A non-nil error is returned to the monkey program as an error
+func WrapFunction(name string, fn interface{}) (object.BuiltinFunction, error) {
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot wrap %T as a builtin, it is not a function", fn)
+	}
+
+	functionType := function.Type()
+	switch {
+	case functionType.NumOut() > 2:
+		return nil, fmt.Errorf("cannot wrap %s as a builtin, it returns more than two values", functionType)
+	case functionType.NumOut() == 2 && functionType.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot wrap %s as a builtin, its second result must be an error", functionType)
+	}
+
+	return func(args ...object.Object) object.Object {
+		in, errorObject := convertArguments(name, functionType, args)
+		if errorObject != nil {
+			return errorObject
+		}
+
+		return convertResults(function.Call(in))
+	}, nil
+}
+
+func convertArguments(name string, functionType reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	parameterCount := functionType.NumIn()
+	if functionType.IsVariadic() {
+		if len(args) < parameterCount-1 {
+			return nil, newError("wrong number of arguments. got=%d, want at least %d", len(args), parameterCount-1)
+		}
+	} else if len(args) != parameterCount {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), parameterCount)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var parameterType reflect.Type
+		if functionType.IsVariadic() && i >= parameterCount-1 {
+			parameterType = functionType.In(parameterCount - 1).Elem()
+		} else {
+			parameterType = functionType.In(i)
+		}
+
+		value, ok := fromObjectAs(arg, parameterType)
+		if !ok && describeType(parameterType) == arg.Type().String() {
+			return nil, newError("argument %d to `%s` is out of range for %s, got %s", i+1, name, parameterType, arg.Inspect())
+		} else if !ok {
+			return nil, newError("argument %d to `%s` must be %s, got %s", i+1, name, describeType(parameterType), arg.Type())
+		}
+		in[i] = value
+	}
+
+	return in, nil
+}
+
+func convertResults(out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return newError("%s", err.Interface())
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return evaluator.NULL
+	}
+
+	result, err := ToObject(out[0].Interface())
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// Converts the monkey value into a Go value of the given type, returning false if it has a
+// different type or would not fit
+func fromObjectAs(o object.Object, t reflect.Type) (reflect.Value, bool) {
+	if t == objectType || (t.Kind() == reflect.Ptr && reflect.TypeOf(o) == t) {
+		return reflect.ValueOf(o), true
+	}
+
+	value := reflect.New(t).Elem()
+
+	if t == bigIntType {
+		switch integer := o.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(big.NewInt(integer.Value)), true
+		case *object.BigInt:
+			return reflect.ValueOf(new(big.Int).Set(integer.Value)), true
+		}
+		return value, false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		boolean, ok := o.(*object.Boolean)
+		if !ok {
+			return value, false
+		}
+		value.SetBool(boolean.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		integer, ok := o.(*object.Integer)
+		if !ok || value.OverflowInt(integer.Value) {
+			return value, false
+		}
+		value.SetInt(integer.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		integer, ok := o.(*object.Integer)
+		if !ok || integer.Value < 0 || value.OverflowUint(uint64(integer.Value)) {
+			return value, false
+		}
+		value.SetUint(uint64(integer.Value))
+	case reflect.Float32, reflect.Float64:
+		switch number := o.(type) {
+		case *object.Float:
+			value.SetFloat(number.Value)
+		case *object.Integer:
+			value.SetFloat(float64(number.Value))
+		case *object.BigInt:
+			converted, _ := new(big.Float).SetInt(number.Value).Float64()
+			value.SetFloat(converted)
+		default:
+			return value, false
+		}
+	case reflect.String:
+		str, ok := o.(*object.String)
+		if !ok {
+			return value, false
+		}
+		value.SetString(str.Value)
+	case reflect.Slice:
+		array, ok := o.(*object.Array)
+		if !ok {
+			return value, false
+		}
+		value.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+		for i, element := range array.Elements {
+			converted, ok := fromObjectAs(element, t.Elem())
+			if !ok {
+				return value, false
+			}
+			value.Index(i).Set(converted)
+		}
+	case reflect.Map:
+		hash, ok := o.(*object.Hash)
+		if !ok {
+			return value, false
+		}
+		value.Set(reflect.MakeMapWithSize(t, len(hash.Pairs)))
+		for _, pair := range hash.Entries() {
+			key, ok := fromObjectAs(pair.Key, t.Key())
+			if !ok {
+				return value, false
+			}
+			converted, ok := fromObjectAs(pair.Value, t.Elem())
+			if !ok {
+				return value, false
+			}
+			value.SetMapIndex(key, converted)
+		}
+	case reflect.Interface:
+		converted, err := FromObject(o)
+		if err != nil {
+			return value, false
+		}
+		if converted == nil {
+			return value, true
+		}
+		if !reflect.TypeOf(converted).AssignableTo(t) {
+			return value, false
+		}
+		value.Set(reflect.ValueOf(converted))
+	default:
+		return value, false
+	}
+
+	return value, true
+}
+
+// Describes the monkey values accepted for a Go type, for use within error messages
+func describeType(t reflect.Type) string {
+	if t == bigIntType {
+		return object.INTEGER.String() + " or " + object.BIGINT.String()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return object.BOOLEAN.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.INTEGER.String()
+	case reflect.Float32, reflect.Float64:
+		return object.FLOAT.String()
+	case reflect.String:
+		return object.STRING.String()
+	case reflect.Slice:
+		return object.ARRAY.String() + " of " + describeType(t.Elem())
+	case reflect.Map:
+		return object.HASH.String() + " of " + describeType(t.Key()) + " to " + describeType(t.Elem())
+	}
+
+	return t.String()
+}
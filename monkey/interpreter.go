@@ -0,0 +1,238 @@
+// Package monkey embeds the monkey language within Go programs.
+//
+//	interpreter := monkey.New()
+//	interpreter.SetGlobal("limit", 10)
+//
+//	program, err := interpreter.Compile("let total = limit * 2; total")
+//	if err != nil {
+//		return err
+//	}
+//
+//	result, err := interpreter.Run(context.Background(), program)
+//
+// Values are converted between Go and monkey automatically, see ToObject and FromObject.
+package monkey
+
+import (
+	"context"
+	"errors"
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/lexer"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/parser"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// Matches the runtime errors of programs which exceeded the interpreter's step or time limits,
+	// or the deadline of their context
+	ErrExecutionLimitExceeded = errors.New(object.ExecutionLimitExceeded)
+	// Matches the runtime errors of programs whose context was cancelled
+	ErrCancelled = errors.New(object.Cancelled)
+)
+
+// Runs monkey programs against a set of global bindings. The globals, including those defined by
+// the programs themselves, are kept between runs
+type Interpreter struct {
+	environment *object.Environment
+	macros      *object.Environment
+	builtins    *object.BuiltinRegistry
+	limits      *object.Limits
+	streams     *object.Streams
+}
+
+// Creates an interpreter with the default builtins, which use the standard streams of the process
+// until given others
+func New() *Interpreter {
+	streams := object.NewStandardStreams()
+	builtins := evaluator.NewBuiltinRegistryWithStreams(streams)
+	limits := &object.Limits{}
+
+	environment := object.NewEnvironmentWithBuiltins(builtins)
+	environment.SetLimits(limits)
+
+	// Macros run while compiling, within the same limits as the programs they expand
+	macros := object.NewEnvironmentWithBuiltins(builtins)
+	macros.SetLimits(limits)
+
+	return &Interpreter{
+		environment: environment,
+		macros:      macros,
+		builtins:    builtins,
+		limits:      limits,
+		streams:     streams,
+	}
+}
+
+// Sets the stream read by builtins
+func (i *Interpreter) SetStdin(stdin io.Reader) {
+	i.streams.Stdin = stdin
+}
+
+// Sets the stream written to by builtins such as puts
+func (i *Interpreter) SetStdout(stdout io.Writer) {
+	i.streams.Stdout = stdout
+}
+
+// Sets the stream builtins write errors to
+func (i *Interpreter) SetStderr(stderr io.Writer) {
+	i.streams.Stderr = stderr
+}
+
+// Limits the number of steps each run may take, such as evaluating an expression. Zero removes
+// the limit
+func (i *Interpreter) SetMaxSteps(steps int64) {
+	i.limits.MaxSteps = steps
+}
+
+// Limits the depth of nested function calls. Zero restores object.DefaultMaxDepth
+func (i *Interpreter) SetMaxDepth(depth int) {
+	i.limits.MaxDepth = depth
+}
+
+// Reports integer overflow as an error rather than promoting the result to a big integer
+func (i *Interpreter) SetCheckOverflow(check bool) {
+	i.limits.CheckOverflow = check
+}
+
+// Limits the duration of each run. Zero removes the limit, although the context given to Run may
+// still have a deadline
+func (i *Interpreter) SetTimeout(timeout time.Duration) {
+	i.limits.Timeout = timeout
+}
+
+// A parsed program, ready to be run by the interpreter which compiled it
+type Program struct {
+	program *ast.Program
+}
+
+// Returned by Compile when the source contains syntax errors, or its macros could not be expanded
+type SyntaxError struct {
+	Errors []*parser.Error
+}
+
+func (e *SyntaxError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Returned by Run when the monkey program stops with an error, such as a type mismatch
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if !e.Object.Pos.IsValid() {
+		return e.Object.Message
+	}
+	return e.Object.Pos.String() + ": " + e.Object.Message
+}
+
+// Allows errors.Is to match ErrExecutionLimitExceeded and ErrCancelled
+func (e *RuntimeError) Unwrap() error {
+	if !e.Object.Interrupted {
+		return nil
+	}
+
+	switch e.Object.Message {
+	case object.ExecutionLimitExceeded:
+		return ErrExecutionLimitExceeded
+	case object.Cancelled:
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Parses the source and expands its macros. Macros defined by the source are available to any
+// programs compiled afterwards
+func (i *Interpreter) Compile(source string) (*Program, error) {
+	return i.CompileContext(context.Background(), source)
+}
+
+// Compiles the source as with Compile. Expanding the macros is stopped with a RuntimeError once the
+// context is done or the interpreter's limits are exceeded, as with Run
+func (i *Interpreter) CompileContext(ctx context.Context, source string) (*Program, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if errors := p.ErrorList(); len(errors) != 0 {
+		return nil, &SyntaxError{Errors: errors}
+	}
+
+	i.limits.Start(ctx)
+	evaluator.DefineMacros(program, i.macros)
+	program, errorObject := evaluator.ExpandMacros(program, i.macros)
+	if errorObject != nil && errorObject.Interrupted {
+		return nil, &RuntimeError{Object: errorObject}
+	} else if errorObject != nil {
+		return nil, &SyntaxError{Errors: []*parser.Error{{Pos: errorObject.Pos, Message: errorObject.Message}}}
+	}
+
+	return &Program{program: program}, nil
+}
+
+// Runs the program, returning the value of its final statement converted with FromObject. The
+// program is stopped with a RuntimeError once the context is done or the interpreter's limits are
+// exceeded
+func (i *Interpreter) Run(ctx context.Context, program *Program) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	i.limits.Start(ctx)
+	result := evaluator.Eval(program.program, i.environment)
+	if errorObject, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Object: errorObject}
+	}
+
+	return FromObject(result)
+}
+
+// Makes the builtin available to the programs run by this interpreter, replacing any existing
+// builtin with the same name
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.builtins.Register(name, fn)
+}
+
+// Makes the Go function available as a builtin, see WrapFunction
+func (i *Interpreter) RegisterFunction(name string, fn interface{}) error {
+	builtin, err := WrapFunction(name, fn)
+	if err != nil {
+		return err
+	}
+
+	i.RegisterBuiltin(name, builtin)
+	return nil
+}
+
+// Binds the name to the Go value, converted with ToObject
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	o, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	i.environment.Add(name, o)
+	return nil
+}
+
+// Returns the value bound to the name, converted with FromObject. The error is set when the value
+// cannot be converted
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool, error) {
+	o, ok := i.environment.Get(name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	value, err := FromObject(o)
+	return value, true, err
+}
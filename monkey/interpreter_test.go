@@ -0,0 +1,338 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/object"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, interpreter *Interpreter, source string) (interface{}, error) {
+	program, err := interpreter.Compile(source)
+	if !assert.NoError(t, err, source) {
+		return nil, err
+	}
+
+	return interpreter.Run(context.Background(), program)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{"1.5 * 2", 3.0},
+		{"1 < 2", true},
+		{`"mon" + "key"`, "monkey"},
+		{"if (false) { 1 }", nil},
+		{`[1, "two", [3]]`, []interface{}{int64(1), "two", []interface{}{int64(3)}}},
+		{`{"a": 1, 2: true}`, map[interface{}]interface{}{"a": int64(1), int64(2): true}},
+	}
+
+	for _, test := range tests {
+		result, err := run(t, New(), test.input)
+		if assert.NoError(t, err, test.input) {
+			assert.Equal(t, test.expected, result, test.input)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interpreter := New()
+	assert.NoError(t, interpreter.SetGlobal("limit", 10))
+	assert.NoError(t, interpreter.SetGlobal("names", []string{"a", "b"}))
+	assert.NoError(t, interpreter.SetGlobal("scores", map[string]float64{"a": 1.5}))
+
+	result, err := run(t, interpreter, `let total = limit * len(names) + int(scores["a"]); total`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(21), result)
+
+	// Globals defined by a program are kept for the following runs
+	total, ok, err := interpreter.GetGlobal("total")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(21), total)
+
+	result, err = run(t, interpreter, "total + 1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(22), result)
+
+	_, ok, err = interpreter.GetGlobal("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	err = interpreter.SetGlobal("channel", make(chan int))
+	assert.EqualError(t, err, "cannot convert chan int to a monkey value")
+}
+
+func TestErrors(t *testing.T) {
+	interpreter := New()
+
+	_, err := interpreter.Compile("let x 1;\nlet y = ;")
+	assert.IsType(t, &SyntaxError{}, err)
+	assert.EqualError(t, err, "1:7: expected '='\n2:9: no prefix parse function for ; found")
+
+	_, err = interpreter.Compile("let m = macro() { 1 }; m()")
+	assert.EqualError(t, err, "1:24: macro m must return a quote, got INTEGER")
+
+	_, err = run(t, interpreter, "let x = 1;\nx + true")
+	if assert.IsType(t, &RuntimeError{}, err) {
+		assert.Equal(t, "type mismatch: INTEGER + BOOLEAN", err.(*RuntimeError).Object.Message)
+	}
+	assert.EqualError(t, err, "2:1: type mismatch: INTEGER + BOOLEAN")
+
+	program, err := interpreter.Compile("1")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = interpreter.Run(ctx, program)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	interpreter := New()
+	program, err := interpreter.Compile("let loop = fn() { while (true) { } };")
+	assert.NoError(t, err)
+	_, err = interpreter.Run(context.Background(), program)
+	assert.NoError(t, err)
+
+	program, err = interpreter.Compile("loop()")
+	assert.NoError(t, err)
+
+	interpreter.SetMaxSteps(1000)
+	_, err = interpreter.Run(context.Background(), program)
+	assert.True(t, errors.Is(err, ErrExecutionLimitExceeded))
+	assert.EqualError(t, err, "1:32: execution limit exceeded")
+
+	interpreter.SetMaxSteps(0)
+	interpreter.SetTimeout(time.Millisecond)
+	_, err = interpreter.Run(context.Background(), program)
+	assert.True(t, errors.Is(err, ErrExecutionLimitExceeded))
+
+	interpreter.SetTimeout(0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = interpreter.Run(ctx, program)
+	assert.True(t, errors.Is(err, ErrExecutionLimitExceeded))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond, cancel)
+	_, err = interpreter.Run(ctx, program)
+	assert.True(t, errors.Is(err, ErrCancelled))
+	assert.False(t, errors.Is(err, ErrExecutionLimitExceeded))
+
+	result, err := run(t, interpreter, "1 + 2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result)
+
+	// Macros are expanded within the same limits
+	interpreter.SetMaxSteps(1000)
+	_, err = interpreter.Compile("let m = macro() { while (true) { } }; m()")
+	assert.True(t, errors.Is(err, ErrExecutionLimitExceeded))
+
+	interpreter.SetMaxSteps(0)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = interpreter.CompileContext(ctx, "1")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRecursionDepth(t *testing.T) {
+	interpreter := New()
+	_, err := run(t, interpreter, "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };")
+	assert.NoError(t, err)
+
+	result, err := run(t, interpreter, "count(100)")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), result)
+
+	interpreter.SetMaxDepth(50)
+	_, err = run(t, interpreter, "count(100)")
+	assert.EqualError(t, err, "1:50: maximum recursion depth exceeded")
+	assert.False(t, errors.Is(err, ErrExecutionLimitExceeded))
+}
+
+func TestCheckOverflow(t *testing.T) {
+	interpreter := New()
+	result, err := run(t, interpreter, "9223372036854775807 + 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "9223372036854775808", fmt.Sprint(result))
+
+	interpreter.SetCheckOverflow(true)
+	_, err = run(t, interpreter, "9223372036854775807 + 1")
+	assert.EqualError(t, err, "1:1: integer overflow: 9223372036854775807 + 1")
+}
+
+func TestMacrosAreKeptBetweenPrograms(t *testing.T) {
+	interpreter := New()
+	_, err := run(t, interpreter, "let double = macro(x) { quote(unquote(x) * 2) };")
+	assert.NoError(t, err)
+
+	result, err := run(t, interpreter, "double(21)")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), result)
+}
+
+func TestToObject(t *testing.T) {
+	number := 5
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{float32(0.5), "0.5"},
+		{"monkey", "monkey"},
+		{&number, "5"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]interface{}{1, "a", nil}, "[1, a, null]"},
+		{map[int]string{2: "b", 1: "a"}, "{1: a, 2: b}"},
+		{&object.Integer{Value: 3}, "3"},
+		{new(big.Int).Lsh(big.NewInt(1), 64), "18446744073709551616n"},
+	}
+
+	for _, test := range tests {
+		o, err := ToObject(test.input)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.expected, o.Inspect())
+		}
+	}
+
+	_, err := ToObject(uint64(1 << 63))
+	assert.EqualError(t, err, "cannot convert 9223372036854775808 to INTEGER, it is out of range")
+
+	_, err = ToObject(map[float64]int{1.5: 1})
+	assert.EqualError(t, err, "unusable as hash key: FLOAT")
+}
+
+func TestFromObject(t *testing.T) {
+	function, err := run(t, New(), "fn(x) { x }")
+	assert.NoError(t, err)
+	assert.IsType(t, &object.Function{}, function)
+
+	shared, err := run(t, New(), "let a = [1]; [a, a]")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{int64(1)}, []interface{}{int64(1)}}, shared)
+
+	_, err = run(t, New(), "let a = [1]; a[0] = a; a")
+	assert.EqualError(t, err, "cannot convert ARRAY to a Go value, it contains itself")
+
+	_, err = run(t, New(), `let h = {"a": 1}; h["a"] = [h]; h`)
+	assert.EqualError(t, err, "cannot convert HASH to a Go value, it contains itself")
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interpreter := New()
+	interpreter.RegisterBuiltin("answer", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+
+	result, err := run(t, interpreter, "answer() + len([1])")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(43), result)
+
+	// Builtins are registered per interpreter
+	_, err = run(t, New(), "answer()")
+	assert.EqualError(t, err, "1:1: identifier not found: answer")
+}
+
+func TestStreams(t *testing.T) {
+	interpreter := New()
+	var first, second bytes.Buffer
+
+	interpreter.SetStdout(&first)
+	_, err := run(t, interpreter, `puts("hello")`)
+	assert.NoError(t, err)
+
+	interpreter.SetStdout(&second)
+	_, err = run(t, interpreter, `puts("world")`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "hello\n", first.String())
+	assert.Equal(t, "world\n", second.String())
+}
+
+func TestRegisterFunction(t *testing.T) {
+	interpreter := New()
+	assert.NoError(t, interpreter.RegisterFunction("startsWith", func(n int64, s string) (bool, error) {
+		if n < 0 {
+			return false, errors.New("n must not be negative")
+		}
+		return strings.HasPrefix(s, strconv.FormatInt(n, 10)), nil
+	}))
+	assert.NoError(t, interpreter.RegisterFunction("sum", func(numbers ...float64) float64 {
+		total := 0.0
+		for _, number := range numbers {
+			total += number
+		}
+		return total
+	}))
+	assert.NoError(t, interpreter.RegisterFunction("keys", func(hash map[string]int) []string {
+		var keys []string
+		for key := range hash {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}))
+	assert.NoError(t, interpreter.RegisterFunction("describe", func(value interface{}) string {
+		return fmt.Sprintf("%T", value)
+	}))
+	assert.NoError(t, interpreter.RegisterFunction("nothing", func(int8) {}))
+	assert.NoError(t, interpreter.RegisterFunction("double", func(n *big.Int) *big.Int {
+		return n.Lsh(n, 1)
+	}))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`startsWith(12, "123")`, "true"},
+		{`startsWith(2, "123")`, "false"},
+		{`startsWith(-1, "123")`, "ERROR: n must not be negative"},
+		{`startsWith("12", "123")`, "ERROR: argument 1 to `startsWith` must be INTEGER, got STRING"},
+		{`startsWith(12)`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{"sum()", "0.0"},
+		{"sum(1, 2.5)", "3.5"},
+		{`sum(1, "2")`, "ERROR: argument 2 to `sum` must be FLOAT, got STRING"},
+		{`keys({"b": 1, "a": 2})`, "[a, b]"},
+		{`keys({"b": "1"})`, "ERROR: argument 1 to `keys` must be HASH of STRING to INTEGER, got HASH"},
+		{`describe([1, "a"])`, "[]interface {}"},
+		{"nothing(127)", "null"},
+		{"nothing(128)", "ERROR: argument 1 to `nothing` is out of range for int8, got 128"},
+		{"sum(2n, 0.5)", "2.5"},
+		{"double(9223372036854775807)", "18446744073709551614n"},
+		{"let n = 2n; double(n); n", "2n"},
+		{"double(1.5)", "ERROR: argument 1 to `double` must be INTEGER or BIGINT, got FLOAT"},
+	}
+
+	for _, test := range tests {
+		program, err := interpreter.Compile(test.input)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		result := evaluator.Eval(program.program, interpreter.environment)
+		assert.Equal(t, test.expected, result.Inspect(), test.input)
+	}
+}
+
+func TestWrapFunctionErrors(t *testing.T) {
+	_, err := WrapFunction("x", 5)
+	assert.EqualError(t, err, "cannot wrap int as a builtin, it is not a function")
+
+	_, err = WrapFunction("x", func() (int, int) { return 1, 2 })
+	assert.EqualError(t, err, "cannot wrap func() (int, int) as a builtin, its second result must be an error")
+}
@@ -0,0 +1,33 @@
+package object
+
+// A set of builtin functions. Each builtin keeps the index it was first registered with, allowing
+// compiled code to refer to builtins by index
+type BuiltinRegistry struct {
+	names    []string
+	builtins map[string]*Builtin
+}
+
+func NewBuiltinRegistry() *BuiltinRegistry {
+	return &BuiltinRegistry{
+		names:    []string{},
+		builtins: make(map[string]*Builtin),
+	}
+}
+
+// Adds the builtin, replacing any existing builtin with the same name
+func (r *BuiltinRegistry) Register(name string, fn BuiltinFunction) {
+	if _, ok := r.builtins[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.builtins[name] = &Builtin{Fn: fn}
+}
+
+func (r *BuiltinRegistry) Lookup(name string) (*Builtin, bool) {
+	builtin, ok := r.builtins[name]
+	return builtin, ok
+}
+
+// Returns the names of the builtins, indexed by the order they were registered in
+func (r *BuiltinRegistry) Names() []string {
+	return r.names
+}
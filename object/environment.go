@@ -1,29 +1,107 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	values map[string]Object
-	parent *Environment
+	values  map[string]Object
+	parent  *Environment
+	modules *ModuleRegistry
+	// The builtins available to the environment, nil when only the default builtins are available
+	builtins *BuiltinRegistry
+	limits   *Limits
+	// Whether the environment only holds the bindings of a block, adding any others to its parent
+	block bool
 }
 
 func NewEnvironment() *Environment {
+	return NewModuleEnvironment(NewModuleRegistry(), nil)
+}
+
+// Creates a top level environment with its own set of builtins
+func NewEnvironmentWithBuiltins(builtins *BuiltinRegistry) *Environment {
+	return NewModuleEnvironment(NewModuleRegistry(), builtins)
+}
+
+// Creates a top level environment which shares its imported modules and builtins with other
+// environments, as used when evaluating an imported module
+func NewModuleEnvironment(modules *ModuleRegistry, builtins *BuiltinRegistry) *Environment {
 	return &Environment{
-		values: make(map[string]Object),
-		parent: nil,
+		values:   make(map[string]Object),
+		parent:   nil,
+		modules:  modules,
+		builtins: builtins,
+		limits:   &Limits{},
 	}
 }
 
 func NewClosedEnvironment(parent *Environment) *Environment {
 	return &Environment{
-		values: make(map[string]Object),
-		parent: parent,
+		values:   make(map[string]Object),
+		parent:   parent,
+		modules:  parent.modules,
+		builtins: parent.builtins,
+		limits:   parent.limits,
+	}
+}
+
+// Creates an environment which binds a name for the duration of a block, such as the parameter of a
+// catch block. Other bindings made within the block are added to the parent environment
+func NewBlockEnvironment(parent *Environment, identifier string, o Object) *Environment {
+	environment := NewClosedEnvironment(parent)
+	environment.values[identifier] = o
+	environment.block = true
+	return environment
+}
+
+func (e *Environment) Modules() *ModuleRegistry {
+	return e.modules
+}
+
+func (e *Environment) Builtins() *BuiltinRegistry {
+	return e.builtins
+}
+
+// Limits the evaluation within this environment, and any environments created from it afterwards
+func (e *Environment) SetLimits(limits *Limits) {
+	e.limits = limits
+}
+
+func (e *Environment) Limits() *Limits {
+	return e.limits
+}
+
+// Returns the names bound directly within this environment, excluding any parent environments
+func (e *Environment) Names() []string {
+	var names []string
+	for name := range e.values {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	return names
 }
 
 func (e *Environment) Add(identifier string, o Object) Object {
+	if _, ok := e.values[identifier]; e.block && !ok {
+		return e.parent.Add(identifier, o)
+	}
 	e.values[identifier] = o
 	return o
 }
 
+// Updates an existing binding, within the closest environment which declared it. Returns false if
+// the identifier was never declared
+func (e *Environment) Set(identifier string, o Object) (Object, bool) {
+	if _, ok := e.values[identifier]; ok {
+		e.values[identifier] = o
+		return o, true
+	}
+	if e.parent != nil {
+		return e.parent.Set(identifier, o)
+	}
+	return nil, false
+}
+
 func (e *Environment) Get(identifier string) (Object, bool) {
 	obj, ok := e.values[identifier]
 	if !ok && e.parent != nil {
@@ -0,0 +1,115 @@
+package object
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ExecutionLimitExceeded   = "execution limit exceeded"
+	Cancelled                = "cancelled"
+	MaxRecursionDepthReached = "maximum recursion depth exceeded"
+)
+
+// The depth of nested function calls allowed when no MaxDepth is given, which stays well within
+// the Go stack used by the evaluator
+const DefaultMaxDepth = 10000
+
+// The context and deadline are only checked periodically, as checking them is relatively slow
+const limitCheckInterval = 1024
+
+// Bounds how long a program may run, and optionally the range of its integers. The same limits are
+// shared by everything a program runs, including imported modules and functions defined by earlier
+// runs. A nil *Limits has no limits
+type Limits struct {
+	MaxSteps      int64         // The maximum number of steps within a single run, zero for no limit
+	Timeout       time.Duration // The maximum duration of a single run, zero for no limit
+	MaxDepth      int           // The maximum depth of nested function calls, zero for DefaultMaxDepth
+	CheckOverflow bool          // Reports integer overflow as an error rather than promoting to a BIGINT
+
+	ctx      context.Context
+	deadline time.Time
+	steps    int64
+	depth    int
+}
+
+// Starts a new run, which may be cancelled through the context
+func (l *Limits) Start(ctx context.Context) {
+	if l == nil {
+		return
+	}
+
+	l.ctx = ctx
+	l.steps = 0
+	l.depth = 0
+	l.deadline = time.Time{}
+	if l.Timeout > 0 {
+		l.deadline = time.Now().Add(l.Timeout)
+	}
+}
+
+func (l *Limits) MaxCallDepth() int {
+	if l == nil || l.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return l.MaxDepth
+}
+
+func (l *Limits) ChecksOverflow() bool {
+	return l != nil && l.CheckOverflow
+}
+
+// Records entering a function call, returning an error rather than entering once the calls are
+// nested too deeply. Each successful Enter must be followed by a Leave
+func (l *Limits) Enter() *Error {
+	if l == nil {
+		return nil
+	}
+
+	if l.depth >= l.MaxCallDepth() {
+		return &Error{Message: MaxRecursionDepthReached}
+	}
+
+	l.depth++
+	return nil
+}
+
+func (l *Limits) Leave() {
+	if l == nil {
+		return
+	}
+
+	l.depth--
+}
+
+// Records a single step of the program, such as evaluating a node or running an instruction.
+// Returns an interrupting error once a limit is exceeded or the run has been cancelled
+func (l *Limits) Step() *Error {
+	if l == nil {
+		return nil
+	}
+
+	l.steps++
+	if l.MaxSteps > 0 && l.steps > l.MaxSteps {
+		return &Error{Message: ExecutionLimitExceeded, Interrupted: true}
+	}
+
+	if l.steps%limitCheckInterval != 0 {
+		return nil
+	}
+
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return &Error{Message: ExecutionLimitExceeded, Interrupted: true}
+	}
+
+	if l.ctx != nil {
+		switch l.ctx.Err() {
+		case context.Canceled:
+			return &Error{Message: Cancelled, Interrupted: true}
+		case context.DeadlineExceeded:
+			return &Error{Message: ExecutionLimitExceeded, Interrupted: true}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package object
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// The bindings exported by an imported .monkey file
+type Module struct {
+	Name    string // The path the module was loaded from
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE
+}
+
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// The modules imported by a program, shared between all of its environments so that each module
+// is only evaluated once
+type ModuleRegistry struct {
+	modules map[string]*Module // Keyed by the absolute path of the module
+	loading []string           // The modules currently being evaluated, outermost first
+}
+
+func NewModuleRegistry() *ModuleRegistry {
+	return &ModuleRegistry{modules: make(map[string]*Module)}
+}
+
+// Modules are identified by their absolute path, so that the same file imported through different
+// paths, such as relative to a different working directory, is the same module
+func moduleKey(path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+	return filepath.Clean(path)
+}
+
+func (r *ModuleRegistry) Get(path string) (*Module, bool) {
+	module, ok := r.modules[moduleKey(path)]
+	return module, ok
+}
+
+func (r *ModuleRegistry) Add(module *Module) {
+	r.modules[moduleKey(module.Name)] = module
+}
+
+// Marks the module as being evaluated. If the module is already being evaluated then importing it
+// again would never finish, so the chain of imports which led back to it is returned instead
+func (r *ModuleRegistry) StartLoading(path string) ([]string, bool) {
+	for index, loading := range r.loading {
+		if moduleKey(loading) == moduleKey(path) {
+			cycle := append([]string{}, r.loading[index:]...)
+			return append(cycle, path), false
+		}
+	}
+
+	r.loading = append(r.loading, path)
+	return nil, true
+}
+
+func (r *ModuleRegistry) FinishLoading(path string) {
+	for index, loading := range r.loading {
+		if moduleKey(loading) == moduleKey(path) {
+			r.loading = append(r.loading[:index], r.loading[index+1:]...)
+			return
+		}
+	}
+}
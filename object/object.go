@@ -3,8 +3,13 @@ package object
 import (
 	"fmt"
 	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/code"
+	"github.com/alanfoster/monkey/token"
 	"bytes"
+	"math/big"
 	"strings"
+	"strconv"
+	"hash/fnv"
 )
 
 type ObjectType int
@@ -21,6 +26,18 @@ const (
 	STRING
 	ARRAY
 	BUILTIN
+	HASH
+	COMPILED_FUNCTION
+	BREAK
+	CONTINUE
+	CELL
+	FLOAT
+	MODULE
+	QUOTE
+	MACRO
+	TAIL_CALL
+	EXCEPTION
+	BIGINT
 )
 
 type Object interface {
@@ -28,6 +45,19 @@ type Object interface {
 	Inspect() string
 }
 
+// The key used to look up an object within a Hash. Including the type ensures that `1` and
+// `true` do not collide, even though their underlying values are the same
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Objects which can be used as the key of a Hash
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
 type Integer struct {
 	Value int64
 }
@@ -40,6 +70,53 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT
+}
+
+// Floats always include a decimal point or exponent, so that they are distinguishable from integers
+func (f *Float) Inspect() string {
+	formatted := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(formatted, ".eIN") {
+		formatted += ".0"
+	}
+	return formatted
+}
+
+// An integer of arbitrary precision. The value is never modified once created, so it may be shared
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT
+}
+
+// Big integers always include the n suffix, so that they are distinguishable from integers
+func (b *BigInt) Inspect() string {
+	return b.Value.String() + "n"
+}
+
+// Big integers which fit within an int64 hash the same as the integer they are equal to
+func (b *BigInt) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return (&Integer{Value: b.Value.Int64()}).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -52,6 +129,15 @@ func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
 
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -74,8 +160,80 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// A single function call which was active when an error occurred
+type StackFrame struct {
+	Function string         // The name of the function, empty for anonymous functions
+	CallSite token.Position // Where the function was called from
+	// When non zero this frame stands in for the given number of calls which were replaced by the
+	// functions they called in tail position, and so are no longer known
+	Omitted int
+}
+
+func (sf StackFrame) String() string {
+	if sf.Omitted != 0 {
+		return fmt.Sprintf("... %d tail calls omitted", sf.Omitted)
+	}
+
+	name := sf.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("in %s, called at %s", name, sf.CallSite)
+}
+
+// Signals that the enclosing loop should stop, unwound through block statements like ReturnValue
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Signals that the enclosing loop should move on to its next iteration
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// A call in tail position of a function body which has not been made yet. It is returned in place
+// of the call's result, so the caller can make the call once its own call has returned
+type TailCall struct {
+	Function  *Function
+	Arguments []Object
+	CallSite  token.Position
+}
+
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_CALL
+}
+
+func (tc *TailCall) Inspect() string {
+	return "tail call"
+}
+
 type Error struct {
 	Message string
+	// Where the error occurred, invalid if this is not known
+	Pos token.Position
+	// The function calls the error propagated through, innermost call first
+	Stack []StackFrame
+	// Set when the program was stopped by its host rather than failing, such as when exceeding its
+	// Limits. See ExecutionLimitExceeded and Cancelled
+	Interrupted bool
+	// Set when the program asked to stop with the exit builtin, in which case the process running
+	// it should exit with ExitCode
+	Exited   bool
+	ExitCode int
+	// The value given to throw, nil for errors raised by the interpreter itself
+	Payload Object
 }
 
 func (e *Error) Type() ObjectType {
@@ -86,7 +244,55 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Whether the error can be caught by a catch block. Errors which stop the program on behalf of
+// its host or the exit builtin are not, and are unwound without running catch or finally blocks
+func (e *Error) Catchable() bool {
+	return !e.Interrupted && !e.Exited
+}
+
+// An error which has been caught, as bound to the parameter of a catch block. Its message,
+// payload and stack can be accessed as members
+type Exception struct {
+	Error *Error
+}
+
+func (e *Exception) Type() ObjectType {
+	return EXCEPTION
+}
+
+func (e *Exception) Inspect() string {
+	return "exception: " + e.Error.Message
+}
+
+// The number of identical consecutive frames shown before the rest are summarised, so that deep
+// recursion does not produce thousands of lines
+const repeatedFramesShown = 3
+
+// Returns the call stack of the error, one frame per line
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(e.Stack); {
+		repeated := 1
+		for i+repeated < len(e.Stack) && e.Stack[i+repeated] == e.Stack[i] {
+			repeated++
+		}
+
+		for j := 0; j < repeated && j < repeatedFramesShown; j++ {
+			out.WriteString("    " + e.Stack[i].String() + "\n")
+		}
+		if repeated > repeatedFramesShown {
+			out.WriteString(fmt.Sprintf("    ... repeated %d more times\n", repeated-repeatedFramesShown))
+		}
+
+		i += repeated
+	}
+
+	return out.String()
+}
+
 type Function struct {
+	Name        string // The name given by the let binding, empty for anonymous functions
 	Parameters  []*ast.Identifier
 	Body        *ast.BlockStatement
 	Environment *Environment
@@ -97,22 +303,115 @@ func (f *Function) Type() ObjectType {
 }
 
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Parameters, f.Body)
+}
+
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	var params []string
-	for _, identifier := range f.Parameters {
+	for _, identifier := range parameters {
 		params = append(params, identifier.Value)
 	}
 
 	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.PrettyPrint())
+	out.WriteString(body.PrettyPrint())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// An unevaluated AST node, produced by `quote(expression)`
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.PrettyPrint() + ")"
+}
+
+// A macro defined by a macro literal. These only exist during macro expansion, when a call to the
+// macro is replaced with the AST node that its body returns
+type Macro struct {
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Environment *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO
+}
+
+func (m *Macro) Inspect() string {
+	return "macro" + strings.TrimPrefix(inspectFunction(m.Parameters, m.Body), "fn")
+}
+
+// A function literal compiled to bytecode. These only exist within the constant pool, at runtime
+// they are always wrapped within a Closure
+type CompiledFunction struct {
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string             // The names of the locals indexed by slot, used to report errors
+	FreeNames     []string             // The names of the free variables indexed by slot, likewise
+	SourceMap     code.SourceMap       // The source positions of the instructions, used to report errors
+	Literal       *ast.FunctionLiteral // The source of the function, used by Inspect
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return inspectFunction(cf.Literal.Parameters, cf.Literal.Body)
+}
+
+// A compiled function along with the free variables it captured. Monkey code can not tell a
+// closure apart from a Function, so it reports the same type
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+	// The program which created the closure, so that functions exported by an imported module keep
+	// running against that module's constants and globals
+	Program *ProgramState
+}
+
+// The constants and globals of a compiled program whilst it runs on the vm
+type ProgramState struct {
+	Constants   []Object
+	Globals     []Object
+	GlobalNames []string // The names of the globals indexed by slot, used to report errors
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION
+}
+
+func (c *Closure) Inspect() string {
+	return c.Fn.Inspect()
+}
+
+// A variable captured by a closure within the vm. Both the closure and the function which declared
+// the variable share the cell, so that assignments made by either are visible to the other
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL
+}
+
+func (c *Cell) Inspect() string {
+	return c.Value.Inspect()
+}
+
 type String struct {
 	Value string
 }
@@ -125,6 +424,13 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -134,11 +440,34 @@ func (a *Array) Type() ObjectType {
 }
 
 func (a *Array) Inspect() string {
+	return a.inspect(map[Object]bool{})
+}
+
+// Arrays and hashes may contain themselves, so those which are already being printed are shown as
+// `[...]` or `{...}` rather than printed forever
+func inspectElement(o Object, printing map[Object]bool) string {
+	switch o := o.(type) {
+	case *Array:
+		return o.inspect(printing)
+	case *Hash:
+		return o.inspect(printing)
+	default:
+		return o.Inspect()
+	}
+}
+
+func (a *Array) inspect(printing map[Object]bool) string {
+	if printing[a] {
+		return "[...]"
+	}
+	printing[a] = true
+	defer delete(printing, a)
+
 	var out bytes.Buffer
 
 	var elements []string
 	for _, element := range a.Elements {
-		elements = append(elements, element.Inspect())
+		elements = append(elements, inspectElement(element, printing))
 	}
 
 	out.WriteString("[")
@@ -148,6 +477,76 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hashes are mutable, as with arrays index assignment calls Set to update the hash in place. The
+// insertion order of keys is remembered so that Inspect, `keys` and `values` are stable
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{
+		Pairs: make(map[HashKey]HashPair),
+		Order: []HashKey{},
+	}
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Order = append(h.Order, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Returns the key/value pairs in insertion order
+func (h *Hash) Entries() []HashPair {
+	var entries []HashPair
+	for _, hashKey := range h.Order {
+		entries = append(entries, h.Pairs[hashKey])
+	}
+	return entries
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH
+}
+
+func (h *Hash) Inspect() string {
+	return h.inspect(map[Object]bool{})
+}
+
+func (h *Hash) inspect(printing map[Object]bool) string {
+	if printing[h] {
+		return "{...}"
+	}
+	printing[h] = true
+	defer delete(printing, h)
+
+	var out bytes.Buffer
+
+	var pairs []string
+	for _, pair := range h.Entries() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+inspectElement(pair.Value, printing))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
 	Fn BuiltinFunction
@@ -4,9 +4,9 @@ package object
 
 import "fmt"
 
-const _ObjectType_name = "INTEGERBOOLEANNULLRETURN_VALUEERRORFUNCTIONSTRINGARRAYBUILTIN"
+const _ObjectType_name = "INTEGERBOOLEANNULLRETURN_VALUEERRORFUNCTIONSTRINGARRAYBUILTINHASHCOMPILED_FUNCTIONBREAKCONTINUECELLFLOATMODULEQUOTEMACROTAIL_CALLEXCEPTIONBIGINT"
 
-var _ObjectType_index = [...]uint8{0, 7, 14, 18, 30, 35, 43, 49, 54, 61}
+var _ObjectType_index = [...]uint8{0, 7, 14, 18, 30, 35, 43, 49, 54, 61, 65, 82, 87, 95, 99, 104, 110, 115, 120, 129, 138, 144}
 
 func (i ObjectType) String() string {
 	i -= 1
@@ -0,0 +1,23 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+// The streams used by builtins which read or write, such as puts. Builtins use the streams as they
+// are when called, so they may be replaced between runs
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Creates streams using the standard streams of the process
+func NewStandardStreams() *Streams {
+	return &Streams{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
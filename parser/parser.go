@@ -5,7 +5,9 @@ import (
 	"github.com/alanfoster/monkey/token"
 	"github.com/alanfoster/monkey/ast"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 type Precedence int
@@ -14,11 +16,19 @@ type Precedence int
 const (
 	_               Precedence = iota
 	LOWEST
+	ASSIGN           // x = y or x += y
+	LOGICAL_OR       // ||
+	LOGICAL_AND      // &&
 	EQUALS           // == or !=
-	LESS_OR_GREATER  // > or <
+	LESS_OR_GREATER  // >, <, >= or <=
+	BITWISE_OR       // |
+	BITWISE_XOR      // ^
+	BITWISE_AND      // &
+	SHIFT            // << or >>
 	SUM              // + or -
-	PRODUCT          // * or /
-	PREFIX           // -X or !X
+	PRODUCT          // *, / or %
+	PREFIX           // -X, !X or ~X
+	POWER            // x ** y
 	CALL             // myFunction(x)
 	INDEX            // array[index]
 )
@@ -26,16 +36,33 @@ const (
 // This particular parser does not make use of a separate left/right precedence, instead they are
 // the same value
 var precedences = map[token.TokenType]Precedence{
-	token.EQ_EQ:        EQUALS,
-	token.NOT_EQ:       EQUALS,
-	token.LESS_THAN:    LESS_OR_GREATER,
-	token.GREATER_THAN: LESS_OR_GREATER,
-	token.PLUS:         SUM,
-	token.MINUS:        SUM,
-	token.SLASH:        PRODUCT,
-	token.ASTERISK:     PRODUCT,
-	token.LEFT_PAREN:   CALL,
-	token.LEFT_BRACKET: INDEX,
+	token.EQ:              ASSIGN,
+	token.PLUS_EQ:         ASSIGN,
+	token.MINUS_EQ:        ASSIGN,
+	token.ASTERISK_EQ:     ASSIGN,
+	token.SLASH_EQ:        ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ_EQ:           EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LESS_THAN:       LESS_OR_GREATER,
+	token.GREATER_THAN:    LESS_OR_GREATER,
+	token.LESS_THAN_EQ:    LESS_OR_GREATER,
+	token.GREATER_THAN_EQ: LESS_OR_GREATER,
+	token.BIT_OR:          BITWISE_OR,
+	token.BIT_XOR:         BITWISE_XOR,
+	token.BIT_AND:         BITWISE_AND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LEFT_PAREN:      CALL,
+	token.LEFT_BRACKET:    INDEX,
+	token.DOT:             INDEX,
 }
 
 type (
@@ -44,20 +71,46 @@ type (
 	infixParseFn func(ast.Expression) ast.Expression
 )
 
+// A syntax error, along with where it was found
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return e.Pos.String() + ": " + e.Message
+}
+
 type Parser struct {
 	l *lexer.Lexer
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
+	// Tokens which were given back by backup, these are read before any further tokens from the lexer
+	pending []token.Token
 
-	errors []string
+	// The number of unclosed braces before the current token, and before the previous token
+	braceDepth     int
+	prevBraceDepth int
+
+	errors []*Error
+	// Set once a syntax error is found, any further errors are suppressed until the parser has
+	// synchronized with the start of the next statement as they are likely to be caused by the first
+	panicking bool
+
+	// The number of loops currently being parsed, break and continue are only valid within a loop
+	loopDepth int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*Error{}}
 
 	// Read two tokens so curToken and peekToken are set
 	p.nextToken()
@@ -67,15 +120,22 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.BIGINT, p.parseBigIntLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBooleanExpression)
 	p.registerPrefix(token.FALSE, p.parseBooleanExpression)
 	p.registerPrefix(token.LEFT_PAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfStatement)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.LEFT_BRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LEFT_BRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -86,15 +146,57 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LESS_THAN, p.parseInfixExpression)
 	p.registerInfix(token.GREATER_THAN, p.parseInfixExpression)
+	p.registerInfix(token.LESS_THAN_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GREATER_THAN_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parsePowerExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.EQ, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_EQ, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_EQ, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_EQ, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_EQ, p.parseAssignExpression)
 	p.registerInfix(token.LEFT_PAREN, p.parseCallExpression)
 	p.registerInfix(token.LEFT_BRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
 
 func (p *Parser) nextToken() {
+	p.prevBraceDepth = p.braceDepth
+	switch p.curToken.Type {
+	case token.LEFT_BRACE:
+		p.braceDepth++
+	case token.RIGHT_BRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
+
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+
+	if n := len(p.pending); n > 0 {
+		p.peekToken = p.pending[n-1]
+		p.pending = p.pending[:n-1]
+	} else {
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// Steps back a single token, used to give back a token which belongs to the next statement
+func (p *Parser) backup() {
+	p.pending = append(p.pending, p.peekToken)
+	p.peekToken = p.curToken
+	p.curToken = p.prevToken
+	p.braceDepth = p.prevBraceDepth
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, prefixParseFn prefixParseFn) {
@@ -118,6 +220,18 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
+
+	// The smallest integer is only in range once negated, so is kept as an integer rather than
+	// promoted to a big integer like other integer literals which are too large
+	if literal, ok := expression.Right.(*ast.BigIntLiteral); ok && expression.Operator == "-" && literal.Token.Type == token.INT {
+		if value := new(big.Int).Neg(literal.Value); value.IsInt64() {
+			return &ast.IntegerLiteral{
+				Token: token.Token{Type: token.INT, Literal: "-" + literal.Token.Literal, Pos: expression.Token.Pos, End: literal.Token.End},
+				Value: value.Int64(),
+			}
+		}
+	}
+
 	return expression
 }
 
@@ -135,12 +249,49 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// Exponentiation is right associative, i.e. `a ** b ** c` is parsed as `a ** (b ** c)`. It binds
+// more tightly than prefix operators, so `-2 ** 2` is `-(2 ** 2)`
+func (p *Parser) parsePowerExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	expression.Right = p.parseExpression(POWER - 1)
+
+	return expression
+}
+
+// Assignment is right associative, i.e. `a = b = c` is parsed as `a = (b = c)`
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(target.Pos(), "invalid assignment target %s", target.PrettyPrint())
+		return p.badExpression(expression.Token)
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	start := p.curToken
 	p.nextToken()
 
 	expression := p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RIGHT_PAREN) {
-		return nil
+		return p.badExpression(start)
 	}
 
 	return expression
@@ -150,18 +301,18 @@ func (p *Parser) parseIfStatement() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LEFT_PAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	p.nextToken()
 	expression.Predicate = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RIGHT_PAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	if !p.expectPeek(token.LEFT_BRACE) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 	expression.TrueBlock = p.parseBlockStatement()
 
@@ -169,7 +320,7 @@ func (p *Parser) parseIfStatement() ast.Expression {
 		p.expectPeek(token.ELSE)
 
 		if !p.expectPeek(token.LEFT_BRACE) {
-			return nil
+			return p.badExpression(expression.Token)
 		}
 
 		expression.FalseBlock = p.parseBlockStatement()
@@ -178,6 +329,45 @@ func (p *Parser) parseIfStatement() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LEFT_BRACE) {
+		return p.badExpression(expression.Token)
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if p.isPeekToken(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LEFT_PAREN) || !p.expectPeek(token.IDENTIFIER) {
+			return p.badExpression(expression.Token)
+		}
+		expression.CatchParameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RIGHT_PAREN) || !p.expectPeek(token.LEFT_BRACE) {
+			return p.badExpression(expression.Token)
+		}
+		expression.CatchBlock = p.parseBlockStatement()
+	}
+
+	if p.isPeekToken(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LEFT_BRACE) {
+			return p.badExpression(expression.Token)
+		}
+		expression.FinallyBlock = p.parseBlockStatement()
+	}
+
+	if expression.CatchBlock == nil && expression.FinallyBlock == nil {
+		p.addError(p.peekToken.Pos, "expected %s or %s", token.Describe(token.CATCH), token.Describe(token.FINALLY))
+		return p.badExpression(expression.Token)
+	}
+
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -192,6 +382,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.EndToken = p.curToken
+
+	if p.isCurToken(token.EOF) {
+		p.appendCurError(token.RIGHT_BRACE)
+	}
 
 	return block
 }
@@ -204,9 +399,12 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	integerLiteral := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+		// Integers too large for an int64 are kept exactly rather than rejected
+		return p.parseBigIntLiteral()
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 	}
 
 	integerLiteral.Value = value
@@ -214,10 +412,41 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return integerLiteral
 }
 
+func (p *Parser) parseBigIntLiteral() ast.Expression {
+	bigIntLiteral := &ast.BigIntLiteral{Token: p.curToken}
+
+	value, ok := new(big.Int).SetString(strings.TrimSuffix(p.curToken.Literal, "n"), 10)
+	if !ok {
+		p.addError(p.curToken.Pos, "could not parse %q as big integer", p.curToken.Literal)
+		value = new(big.Int)
+	}
+
+	bigIntLiteral.Value = value
+
+	return bigIntLiteral
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	floatLiteral := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
+	}
+
+	floatLiteral.Value = value
+
+	return floatLiteral
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	arrayLiteral := &ast.ArrayLiteral{Token: p.curToken}
 	p.expectCur(token.LEFT_BRACKET)
 	arrayLiteral.Elements = p.parseExpressionList()
+	if p.panicking {
+		return p.badExpression(arrayLiteral.Token)
+	}
+	arrayLiteral.EndToken = p.curToken
 
 	return arrayLiteral
 }
@@ -250,45 +479,97 @@ func (p *Parser) parseExpressionList() []ast.Expression {
 	return expressions
 }
 
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hashLiteral := &ast.HashLiteral{Token: p.curToken, Pairs: []*ast.HashPair{}}
+
+	for !p.isPeekToken(token.RIGHT_BRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return p.badExpression(hashLiteral.Token)
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hashLiteral.Pairs = append(hashLiteral.Pairs, &ast.HashPair{Key: key, Value: value})
+
+		if !p.isPeekToken(token.RIGHT_BRACE) && !p.expectPeek(token.COMMA) {
+			return p.badExpression(hashLiteral.Token)
+		}
+	}
+
+	if !p.expectPeek(token.RIGHT_BRACE) {
+		return p.badExpression(hashLiteral.Token)
+	}
+	hashLiteral.EndToken = p.curToken
+
+	return hashLiteral
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	functionLiteral := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LEFT_PAREN) {
-		return nil
+		return p.badExpression(functionLiteral.Token)
 	}
 
 	functionLiteral.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LEFT_BRACE) {
-		return nil
+		return p.badExpression(functionLiteral.Token)
 	}
 
+	// A function body starts a fresh context, so it can not break out of an enclosing loop
+	enclosingLoopDepth := p.loopDepth
+	p.loopDepth = 0
 	functionLiteral.Body = p.parseBlockStatement()
+	p.loopDepth = enclosingLoopDepth
 
 	return functionLiteral
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macroLiteral := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LEFT_PAREN) {
+		return p.badExpression(macroLiteral.Token)
+	}
+
+	macroLiteral.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LEFT_BRACE) {
+		return p.badExpression(macroLiteral.Token)
+	}
+
+	enclosingLoopDepth := p.loopDepth
+	p.loopDepth = 0
+	macroLiteral.Body = p.parseBlockStatement()
+	p.loopDepth = enclosingLoopDepth
+
+	return macroLiteral
+}
+
+// Parses the parameters from the current left paren up to and including the right paren
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
-	p.expectCur(token.LEFT_PAREN)
-
-	for !p.isCurToken(token.RIGHT_PAREN) {
+	for !p.isPeekToken(token.RIGHT_PAREN) {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
 		identifier := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, identifier)
-		p.expectCur(token.IDENTIFIER)
 
-		if !p.isCurToken(token.COMMA) {
+		if !p.isPeekToken(token.COMMA) {
 			break
 		}
-
-		if !p.expectCur(token.COMMA) {
-			return nil
-		}
+		p.nextToken()
 	}
 
-	// The convention is the calling of next function consumes the next token
-	// p.expectCur(token.RIGHT_PAREN)
+	if !p.expectPeek(token.RIGHT_PAREN) {
+		return nil
+	}
 
 	return identifiers
 }
@@ -299,6 +580,10 @@ func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 		Function: left,
 	}
 	expression.Arguments = p.parseFunctionArguments()
+	if p.panicking {
+		return p.badExpression(expression.Token)
+	}
+	expression.EndToken = p.curToken
 
 	return expression
 }
@@ -309,32 +594,53 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	indexExpression.Index = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RIGHT_BRACKET) {
-		return nil
+		return p.badExpression(indexExpression.Token)
 	}
+	indexExpression.EndToken = p.curToken
 
 	return indexExpression
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	memberExpression := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return p.badExpression(memberExpression.Token)
+	}
+	memberExpression.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return memberExpression
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	importExpression := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return p.badExpression(importExpression.Token)
+	}
+	importExpression.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return importExpression
+}
+
+// Parses the arguments from the current left paren up to and including the right paren
 func (p *Parser) parseFunctionArguments() []ast.Expression {
 	var args []ast.Expression
-	p.expectCur(token.LEFT_PAREN)
 
-	for !p.isCurToken(token.RIGHT_PAREN) {
+	for !p.isPeekToken(token.RIGHT_PAREN) {
+		p.nextToken()
 		arg := p.parseExpression(LOWEST)
 		args = append(args, arg)
-		p.nextToken()
-
-		if !p.isCurToken(token.COMMA) {
-			break;
-		}
 
-		if !p.expectCur(token.COMMA) {
-			return nil
+		if !p.isPeekToken(token.COMMA) {
+			break
 		}
+		p.nextToken()
 	}
 
-	// The convention is the calling of next function consumes the next token
-	//p.expectCur(token.RIGHT_PAREN)
+	if !p.expectPeek(token.RIGHT_PAREN) {
+		return nil
+	}
 
 	return args
 }
@@ -343,18 +649,35 @@ func (p *Parser) parseBooleanExpression() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.isCurToken(token.TRUE)}
 }
 
+// The messages of the errors found whilst parsing
 func (p *Parser) Errors() []string {
+	var messages []string
+	for _, err := range p.errors {
+		messages = append(messages, err.Message)
+	}
+	return messages
+}
+
+// The errors found whilst parsing, along with their positions
+func (p *Parser) ErrorList() []*Error {
 	return p.errors
 }
 
+func (p *Parser) addError(pos token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.errors = append(p.errors, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
 func (p *Parser) appendCurError(t token.TokenType) {
-	msg := fmt.Sprintf("expected current token to be %s, but got %s instead", t, p.peekToken)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, "expected %s", token.Describe(t))
 }
 
 func (p *Parser) appendPeekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, but got %s instead", t, p.peekToken)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, "expected %s", token.Describe(t))
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -373,19 +696,85 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	ast.Walk(loopControlChecker{p: p}, program)
+
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+	depth := p.braceDepth
+
+	var statement ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		statement = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		statement = p.parseReturnStatement()
+	case token.THROW:
+		statement = p.parseThrowStatement()
+	case token.WHILE:
+		statement = p.parseWhileStatement()
+	case token.FOR:
+		statement = p.parseForStatement()
+	case token.BREAK:
+		statement = p.parseBreakStatement()
+	case token.CONTINUE:
+		statement = p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		statement = p.parseExpressionStatement()
+	}
+
+	if p.panicking {
+		p.synchronize(start, depth)
+		return &ast.BadStatement{Token: start, EndToken: p.curToken}
+	}
+
+	return statement
+}
+
+// Statements can only begin with these keywords, so they are a safe place to resume parsing
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.THROW:    true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+// Recovers from a syntax error by skipping the remaining tokens of the statement which began with
+// the start token, leaving the current token on the last token of the statement. The statement ends
+// at the next semicolon, closing brace or statement keyword which is not nested within braces
+func (p *Parser) synchronize(start token.Token, depth int) {
+	p.panicking = false
+
+	// The invalid token may already be the start of the next statement, or the end of the block
+	if p.curToken.Pos != start.Pos && p.braceDepth == depth {
+		if statementKeywords[p.curToken.Type] || (p.isCurToken(token.RIGHT_BRACE) && depth > 0) {
+			p.backup()
+			return
+		}
+	}
+
+	for !p.isCurToken(token.EOF) && !p.isPeekToken(token.EOF) {
+		depthAfterCur := p.braceDepth
+		switch p.curToken.Type {
+		case token.LEFT_BRACE:
+			depthAfterCur++
+		case token.RIGHT_BRACE:
+			depthAfterCur--
+		}
+
+		if depthAfterCur <= depth {
+			if p.isCurToken(token.SEMICOLON) || p.isPeekToken(token.RIGHT_BRACE) || statementKeywords[p.peekToken.Type] {
+				return
+			}
+		}
+
+		p.nextToken()
 	}
-	return nil
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
@@ -401,21 +790,25 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+// Creates a placeholder for an invalid expression, from the given token up to the current token
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{Token: start, EndToken: p.curToken}
+}
+
 func (p *Parser) appendPrefixParseError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.appendPrefixParseError(p.curToken.Type)
-		return nil
+		return p.badExpression(p.curToken)
 	}
 
 	leftExp := prefix()
 
-	for !p.isPeekToken(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	for !p.panicking && !p.isPeekToken(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -463,6 +856,170 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.isPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LEFT_PAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RIGHT_PAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LEFT_BRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LEFT_PAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RIGHT_PAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LEFT_BRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.isPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return body
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if p.isPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+		p.addError(stmt.Token.Pos, "break is only allowed within a loop")
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if p.isPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+		p.addError(stmt.Token.Pos, "continue is only allowed within a loop")
+		return nil
+	}
+
+	return stmt
+}
+
+// Reports break and continue statements within an expression whose value is used, such as
+// `[if (x) { break }]`, as the expression would be left part way through being evaluated. An if or
+// try expression which is itself a statement is not used as a value
+type loopControlChecker struct {
+	p     *Parser
+	value bool
+}
+
+func (c loopControlChecker) Visit(node ast.Node) ast.Visitor {
+	valueChecker := loopControlChecker{p: c.p, value: true}
+
+	switch node := node.(type) {
+	case nil:
+		return nil
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		if c.value {
+			c.p.errors = append(c.p.errors, &Error{Pos: node.Pos(), Message: fmt.Sprintf("%s is not allowed within an expression", node.TokenLiteral())})
+		}
+		return nil
+	case *ast.ExpressionStatement:
+		switch expression := node.Expression.(type) {
+		case *ast.IfExpression:
+			ast.Walk(valueChecker, expression.Predicate)
+			c.walkBlocks(expression.TrueBlock, expression.FalseBlock)
+			return nil
+		case *ast.TryExpression:
+			c.walkBlocks(expression.Body, expression.CatchBlock, expression.FinallyBlock)
+			return nil
+		}
+		return c
+	case *ast.WhileStatement:
+		ast.Walk(valueChecker, node.Condition)
+		ast.Walk(loopControlChecker{p: c.p}, node.Body)
+		return nil
+	case *ast.ForStatement:
+		ast.Walk(valueChecker, node.Iterable)
+		ast.Walk(loopControlChecker{p: c.p}, node.Body)
+		return nil
+	case *ast.FunctionLiteral:
+		ast.Walk(loopControlChecker{p: c.p}, node.Body)
+		return nil
+	case *ast.MacroLiteral:
+		ast.Walk(loopControlChecker{p: c.p}, node.Body)
+		return nil
+	case ast.Expression:
+		return valueChecker
+	}
+
+	return c
+}
+
+func (c loopControlChecker) walkBlocks(blocks ...*ast.BlockStatement) {
+	for _, block := range blocks {
+		if block != nil {
+			ast.Walk(c, block)
+		}
+	}
+}
+
 func (p *Parser) isCurToken(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"math"
 	"testing"
 	"github.com/stretchr/testify/assert"
+	"github.com/alanfoster/monkey/ast"
 	"github.com/alanfoster/monkey/lexer"
 	"github.com/bradleyjkemp/cupaloy"
 )
@@ -17,12 +19,96 @@ func TestInvalidParsing(t *testing.T) {
 
 	p.ParseProgram()
 	expectedErrors := []string{
-		"expected next token to be =, but got {INT 10} instead",
-		"expected next token to be =, but got {EOF } instead",
+		"expected '='",
+		"expected '='",
 	}
 	assert.Equal(t, expectedErrors, p.Errors())
 }
 
+func TestErrorPositions(t *testing.T) {
+	input := "let x 10;\n\treturn (1;"
+
+	l := lexer.NewFile("example.monkey", input)
+	p := New(l)
+	p.ParseProgram()
+
+	var errors []string
+	for _, err := range p.ErrorList() {
+		errors = append(errors, err.Error())
+	}
+
+	expectedErrors := []string{
+		"example.monkey:1:7: expected '='",
+		"example.monkey:2:11: expected ')'",
+	}
+	assert.Equal(t, expectedErrors, errors)
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"let x 10;\nlet y = (1 + 2;\nputs(1 2);\nlet z = 3;",
+			[]string{"1:7: expected '='", "2:15: expected ')'", "3:8: expected ')'"},
+		},
+		{
+			"let x = ;\nlet f = fn(a b) { a };\nlet h = {1 2};\nf(1)",
+			[]string{"1:9: no prefix parse function for ; found", "2:14: expected ')'", "3:12: expected ':'"},
+		},
+		{
+			"let f = fn() {\n  let x = \n}\nlet y = 1 +\nlet z = [1 2]\nz",
+			[]string{"3:1: no prefix parse function for } found", "5:1: no prefix parse function for LET found", "5:12: expected ']'"},
+		},
+		{
+			"break;\nwhile (true) { 1 +; }\nfn() { 1",
+			[]string{"1:1: break is only allowed within a loop", "2:19: no prefix parse function for ; found", "3:9: expected '}'"},
+		},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.ErrorList() {
+			errors = append(errors, err.Error())
+		}
+		assert.Equal(t, test.expectedErrors, errors, test.input)
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	input := "let x = ;\nlet y = fn() { 1 + ; 2 };\ny()"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	assert.Len(t, p.Errors(), 2)
+	assert.Len(t, program.Statements, 3)
+
+	badStatement, ok := program.Statements[0].(*ast.BadStatement)
+	assert.True(t, ok, "expected a bad statement, got %T", program.Statements[0])
+	assert.Equal(t, "let", badStatement.Token.Literal)
+	assert.Equal(t, ";", badStatement.EndToken.Literal)
+
+	letStatement, ok := program.Statements[1].(*ast.LetStatement)
+	assert.True(t, ok, "expected a let statement, got %T", program.Statements[1])
+	body := letStatement.Value.(*ast.FunctionLiteral).Body
+	assert.IsType(t, &ast.BadStatement{}, body.Statements[0])
+	assert.Equal(t, "2", body.Statements[1].PrettyPrint())
+
+	assert.Equal(t, "y()", program.Statements[2].PrettyPrint())
+
+	p = New(lexer.New("let x = (1 + 2;"))
+	program = p.ParseProgram()
+	assert.IsType(t, &ast.BadStatement{}, program.Statements[0])
+
+	p = New(lexer.New("[1, 2 3]"))
+	expression := p.parseExpression(LOWEST)
+	assert.IsType(t, &ast.BadExpression{}, expression)
+}
+
 func TestLetStatements(t *testing.T) {
 	input := `
 		let x = 5;
@@ -70,6 +156,79 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	cupaloy.SnapshotT(t, program)
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"0.5", 0.5},
+		{"1e9", 1e9},
+		{"2.5E-3", 2.5e-3},
+		{"1e+2", 100},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := statement.Expression.(*ast.FloatLiteral)
+		if assert.True(t, ok, test.input) {
+			assert.Equal(t, test.expected, literal.Value, test.input)
+			assert.Equal(t, test.input, literal.PrettyPrint())
+		}
+	}
+}
+
+func TestBigIntLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10n", "10"},
+		{"0n", "0"},
+		{"123456789012345678901234567890n", "123456789012345678901234567890"},
+		{"9223372036854775808", "9223372036854775808"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := statement.Expression.(*ast.BigIntLiteral)
+		if assert.True(t, ok, test.input) {
+			assert.Equal(t, test.expected, literal.Value.String(), test.input)
+			assert.Equal(t, test.input, literal.PrettyPrint())
+		}
+	}
+}
+
+func TestSmallestIntegerLiteral(t *testing.T) {
+	l := lexer.New("-9223372036854775808")
+	p := New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.IntegerLiteral)
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(math.MinInt64), literal.Value)
+		assert.Equal(t, "-9223372036854775808", literal.PrettyPrint())
+	}
+
+	// Other negated literals which are too large remain big integers
+	for _, input := range []string{"-9223372036854775809", "-9223372036854775808n"} {
+		statement := New(lexer.New(input)).ParseProgram().Statements[0].(*ast.ExpressionStatement)
+		assert.IsType(t, &ast.PrefixExpression{}, statement.Expression, input)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	input := `!5; -15;`
 	l := lexer.New(input)
@@ -205,6 +364,62 @@ func TestOperatorPrecedence(t *testing.T) {
 			"func([1, 2, 3])",
 			"func([1, 2, 3])",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b * c",
+			"((-(a ** b)) * c)",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"f(a) ** b[0]",
+			"(f(a) ** (b[0]))",
+		},
+		{
+			"a | b ^ c & d << e + f",
+			"(a | (b ^ (c & (d << (e + f)))))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a >> b < c >> d",
+			"((a >> b) < (c >> d))",
+		},
+		{
+			"~a & ~b",
+			"((~a) & (~b))",
+		},
 	}
 
 	for _, test := range tests {
@@ -360,6 +575,23 @@ func TestFunctionLiteral(t *testing.T) {
 	cupaloy.SnapshotT(t, program)
 }
 
+func TestMacroLiteral(t *testing.T) {
+	input := "macro(x, y) { x + y; }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	assert.Len(t, program.Statements, 1)
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	macro, ok := statement.Expression.(*ast.MacroLiteral)
+	if assert.True(t, ok, "expected a macro literal, got %T", statement.Expression) {
+		assert.Len(t, macro.Parameters, 2)
+		assert.Equal(t, "macro(x, y) { (x + y); }", macro.PrettyPrint())
+	}
+}
+
 func TestFunctionLiteralArity(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -418,3 +650,355 @@ func TestInlinedFunctionCall(t *testing.T) {
 
 	cupaloy.SnapshotT(t, program)
 }
+
+func TestHashLiteral(t *testing.T) {
+	input := `
+		let a = {"one": 1, two: 1 + 1, 3: fn(x) { x }}
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	cupaloy.SnapshotT(t, program)
+}
+
+func TestHashLiteralArity(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPrettyText string
+	}{
+		{
+			"{}",
+			"{}",
+		},
+		{
+			`{"one": 1}`,
+			"{one: 1}",
+		},
+		{
+			`{"one": 1, "two": 2}`,
+			"{one: 1, two: 2}",
+		},
+		{
+			`{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`,
+			"{one: (0 + 1), two: (10 - 8), three: (15 / 5)}",
+		},
+		{
+			`{true: [1, 2], 1: {"nested": 2}}["key"]`,
+			"({true: [1, 2], 1: {nested: 2}}[key])",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+		assert.Equal(t, test.expectedPrettyText, program.PrettyPrint())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart string
+		expectedEnd   string
+	}{
+		{"let x = 5;", "1:1", "1:10"},
+		{"return foo", "1:1", "1:11"},
+		{"  1 + 2 * 3", "1:3", "1:12"},
+		{"-a", "1:1", "1:3"},
+		{`"hello"`, "1:1", "1:8"},
+		{"[1, 2]", "1:1", "1:7"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"add(1,\n 2)", "1:1", "2:4"},
+		{"array[0]", "1:1", "1:9"},
+		{"fn(x) {\n  x\n}", "1:1", "3:2"},
+		{"if (x) { 1 } else { 2 }", "1:1", "1:24"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+		assert.Equal(t, test.expectedStart, program.Pos().String(), test.input)
+		assert.Equal(t, test.expectedEnd, program.End().String(), test.input)
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `
+		while (x < 10) {
+			if (x == 5) { break; }
+			continue
+		}
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	cupaloy.SnapshotT(t, program)
+}
+
+func TestForStatement(t *testing.T) {
+	input := `
+		for (x in [1, 2]) {
+			puts(x)
+		}
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	cupaloy.SnapshotT(t, program)
+}
+
+func TestLoopPrettyPrint(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPrettyText string
+	}{
+		{
+			"while (true) { }",
+			"while (true) {}",
+		},
+		{
+			"while (a < b) { a; break; }",
+			"while ((a < b)) {a;break;}",
+		},
+		{
+			"for (x in xs) { continue; x }",
+			"for (x in xs) {continue;x;}",
+		},
+		{
+			"while (a) { fn() { 1 }; while (b) { break } }",
+			"while (a) {fn() { 1; };while (b) {break;};}",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+		assert.Equal(t, test.expectedPrettyText, program.PrettyPrint())
+	}
+}
+
+func TestInvalidLoopControl(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"break;",
+			[]string{"break is only allowed within a loop"},
+		},
+		{
+			"if (true) { continue }",
+			[]string{"continue is only allowed within a loop"},
+		},
+		{
+			"while (true) { fn() { break } }",
+			[]string{"break is only allowed within a loop"},
+		},
+		{
+			"while (true) { let v = [if (true) { break }] }",
+			[]string{"break is not allowed within an expression"},
+		},
+		{
+			"for (x in [1]) { let y = 1 + if (x == 2) { continue } else { 0 } }",
+			[]string{"continue is not allowed within an expression"},
+		},
+		{
+			"while (true) { let v = if (true) { if (false) { break }; 1 } }",
+			[]string{"break is not allowed within an expression"},
+		},
+		{
+			"while (true) { let v = try { continue } catch (e) { 1 } }",
+			[]string{"continue is not allowed within an expression"},
+		},
+		{
+			"while (true) { if (true) { if (false) { break } } else { try { continue } finally { 1 } } }",
+			nil,
+		},
+		{
+			"while (true) { let v = 1 + if (true) { while (true) { break }; 1 } }",
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		p.ParseProgram()
+		assert.Equal(t, test.expectedErrors, p.Errors(), test.input)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPrettyText string
+	}{
+		{
+			"try { risky() } catch (e) { puts(e) }",
+			"try {risky();} catch (e) {puts(e);}",
+		},
+		{
+			"try { risky() } finally { cleanup() }",
+			"try {risky();} finally {cleanup();}",
+		},
+		{
+			"let x = try { 1 } catch (e) { 2 } finally { 3 };",
+			"let x = try {1;} catch (e) {2;} finally {3;};",
+		},
+		{
+			"throw 1 + 2; throw {\"code\": 1}",
+			"throw (1 + 2);throw {code: 1};",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors(), test.input)
+		assert.Equal(t, test.expectedPrettyText, program.PrettyPrint(), test.input)
+	}
+}
+
+func TestInvalidTryExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"try { 1 }; 2", []string{"1:10: expected 'catch' or 'finally'"}},
+		{"try { 1 } catch { 2 }", []string{"1:17: expected '('"}},
+		{"try { 1 } catch (1) { 2 }", []string{"1:18: expected identifier"}},
+		{"try { 1 } finally 2", []string{"1:19: expected '{'"}},
+		{"throw;\nlet x = 1", []string{"1:6: no prefix parse function for ; found"}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.ErrorList() {
+			errors = append(errors, err.Error())
+		}
+		assert.Equal(t, test.expectedErrors, errors, test.input)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPrettyText string
+	}{
+		{
+			"x = 5",
+			"(x = 5)",
+		},
+		{
+			"x += 1 + 2",
+			"(x += (1 + 2))",
+		},
+		{
+			"a = b = c",
+			"(a = (b = c))",
+		},
+		{
+			"a[1 + 1] *= b == c",
+			"((a[(1 + 1)]) *= (b == c))",
+		},
+		{
+			"x -= 1; x /= 2",
+			"(x -= 1)(x /= 2)",
+		},
+		{
+			"let f = fn() { count += 1 }",
+			"let f = fn() { (count += 1); };",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors(), test.input)
+		assert.Equal(t, test.expectedPrettyText, program.PrettyPrint(), test.input)
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"1 = 2",
+			[]string{"invalid assignment target 1"},
+		},
+		{
+			"f() += 1",
+			[]string{"invalid assignment target f()"},
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		p.ParseProgram()
+		assert.Equal(t, test.expectedErrors, p.Errors(), test.input)
+	}
+}
+
+func TestImportAndMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPrettyText string
+	}{
+		{
+			`let lib = import "lib.monkey"`,
+			`let lib = import "lib.monkey";`,
+		},
+		{
+			"lib.map(xs, f)",
+			"(lib.map)(xs, f)",
+		},
+		{
+			"a.b.c[0] + 1",
+			"((((a.b).c)[0]) + 1)",
+		},
+		{
+			"-lib.x",
+			"(-(lib.x))",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors(), test.input)
+		assert.Equal(t, test.expectedPrettyText, program.PrettyPrint(), test.input)
+	}
+}
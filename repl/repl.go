@@ -1,14 +1,22 @@
 package repl
 
 import (
+	"context"
 	"io"
+	"io/ioutil"
 	"bufio"
+	"github.com/alanfoster/monkey/ast"
 	"github.com/alanfoster/monkey/lexer"
 	"github.com/alanfoster/monkey/parser"
 	"github.com/alanfoster/monkey/token"
 	"github.com/alanfoster/monkey/evaluator"
 	"fmt"
 	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/compiler"
+	"github.com/alanfoster/monkey/vm"
+	"os"
+	"os/signal"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -20,28 +28,44 @@ const (
 	LEX
 	PARSE
 	EVAL
+	VM
 )
 
 const (
 	LEX_MODE   = "mode=lex"
 	PARSE_MODE = "mode=parse"
 	EVAL_MODE  = "mode=eval"
+	VM_MODE    = "mode=vm"
 )
 
 type Repl struct {
 	Mode        Mode
 	out         io.Writer
 	environment *object.Environment
+	// The macros defined so far, which are expanded within each following line
+	macros *object.Environment
+	// Restarted for each line, which may also be cancelled with an interrupt
+	limits *object.Limits
+	// Shared by both engines, so that builtins such as puts write to the repl's output
+	builtins *object.BuiltinRegistry
+	// Set once a line calls the exit builtin
+	exited bool
+
+	// The vm state which is carried across lines
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
 }
 
 func (r *Repl) OutputUsage() {
-	fmt.Println("This is the monkey programming language!")
-	fmt.Println("Feel free to type in commands, for example: 1 + 2 + 3")
+	fmt.Fprintln(r.out, "This is the monkey programming language!")
+	fmt.Fprintln(r.out, "Feel free to type in commands, for example: 1 + 2 + 3")
 
 	fmt.Fprintln(r.out, "To set the mode:")
 	fmt.Fprintln(r.out, LEX_MODE)
 	fmt.Fprintln(r.out, PARSE_MODE)
 	fmt.Fprintln(r.out, EVAL_MODE)
+	fmt.Fprintln(r.out, VM_MODE)
 }
 
 func (r *Repl) Configure(line string) bool {
@@ -57,6 +81,10 @@ func (r *Repl) Configure(line string) bool {
 		fmt.Fprintln(r.out, "Entering eval mode")
 		r.Mode = EVAL
 		return true
+	} else if line == VM_MODE {
+		fmt.Fprintln(r.out, "Entering vm mode")
+		r.Mode = VM
+		return true
 	}
 
 	return false
@@ -70,13 +98,15 @@ func (r *Repl) Handle(line string) {
 		r.parse(line)
 	case EVAL:
 		r.eval(line)
+	case VM:
+		r.vm(line)
 	}
 }
 
 func (r *Repl) lex(line string) {
 	l := lexer.New(line)
 	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-		fmt.Fprintf(r.out, "%+v\n", tok)
+		fmt.Fprintf(r.out, "{Type:%s Literal:%s} %s\n", tok.Type, tok.Literal, tok.Pos)
 	}
 }
 
@@ -85,10 +115,10 @@ func (r *Repl) parse(line string) {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	errors := p.Errors()
+	errors := p.ErrorList()
 
 	if len(errors) != 0 {
-		r.printParsingErrors(errors)
+		r.printParsingErrors(errors, line)
 		return
 	}
 
@@ -100,53 +130,176 @@ func (r *Repl) eval(line string) {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	errors := p.Errors()
+	errors := p.ErrorList()
 
 	if len(errors) != 0 {
-		r.printParsingErrors(errors)
+		r.printParsingErrors(errors, line)
 		return
 	}
 
+	program, ok := r.expandMacros(program, line)
+	if !ok || len(program.Statements) == 0 {
+		return
+	}
+
+	stop := r.startLimits()
 	eval := evaluator.Eval(program, r.environment)
+	stop()
+
+	if errorObject, ok := eval.(*object.Error); ok {
+		if errorObject.Exited {
+			r.exited = true
+			return
+		}
+
+		r.printRuntimeError(errorObject, line)
+		return
+	}
+
 	fmt.Fprintln(r.out, eval.Inspect())
 }
 
-func (r *Repl) printParsingErrors(errors []string) {
-	io.WriteString(r.out, "Error: Parsing errors found.")
+func (r *Repl) vm(line string) {
+	l := lexer.New(line)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	errors := p.ErrorList()
+
+	if len(errors) != 0 {
+		r.printParsingErrors(errors, line)
+		return
+	}
+
+	program, ok := r.expandMacros(program, line)
+	if !ok || len(program.Statements) == 0 {
+		return
+	}
+
+	c := compiler.NewWithState(r.symbolTable, r.constants)
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintf(r.out, "Error: Compilation failed: %s\n", err)
+		return
+	}
+
+	bytecode := c.Bytecode()
+	r.constants = bytecode.Constants
+
+	machine := vm.NewWithState(bytecode, r.globals, r.builtins)
+	machine.SetLimits(r.limits)
+
+	stop := r.startLimits()
+	err := machine.Run()
+	stop()
+
+	if err != nil {
+		runtimeError, ok := err.(*vm.RuntimeError)
+		if !ok {
+			fmt.Fprintf(r.out, "ERROR: %s\n", err)
+			return
+		}
+		if runtimeError.Object.Exited {
+			r.exited = true
+			return
+		}
+
+		r.printRuntimeError(runtimeError.Object, line)
+		return
+	}
+
+	fmt.Fprintln(r.out, machine.LastPoppedStackElem().Inspect())
+}
+
+// Starts the limits for running a line, until the returned function is called an interrupt cancels
+// the line rather than exiting the repl
+func (r *Repl) startLimits() context.CancelFunc {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	r.limits.Start(ctx)
+	return stop
+}
+
+// Defines the macros of the line, and expands any calls to previously defined macros
+func (r *Repl) expandMacros(program *ast.Program, line string) (*ast.Program, bool) {
+	stop := r.startLimits()
+	evaluator.DefineMacros(program, r.macros)
+	expanded, errorObject := evaluator.ExpandMacros(program, r.macros)
+	stop()
+
+	if errorObject != nil {
+		r.printRuntimeError(errorObject, line)
+		return nil, false
+	}
+
+	return expanded, true
+}
+
+func (r *Repl) printParsingErrors(errors []*parser.Error, line string) {
+	io.WriteString(r.out, "Error: Parsing errors found.\n")
 	for _, e := range errors {
-		fmt.Fprintf(r.out, "%v\n", e)
+		fmt.Fprintln(r.out, token.FormatError(e.Pos, e.Message, line))
 	}
 }
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+func (r *Repl) printRuntimeError(errorObject *object.Error, line string) {
+	source := line
+	// Errors may have occurred within an imported module rather than the line itself
+	if errorObject.Pos.Filename != "" {
+		data, _ := ioutil.ReadFile(errorObject.Pos.Filename)
+		source = string(data)
+	}
+
+	fmt.Fprintln(r.out, token.FormatError(errorObject.Pos, errorObject.Message, source))
+	io.WriteString(r.out, errorObject.StackTrace())
+}
+
+// Starts an interactive session, each line is run within the given limits. Programs read from and
+// write to the same streams as the session. The session reads its lines through the same buffer as
+// programs, so that neither reads ahead of the other
+func Start(in io.Reader, out io.Writer, limits *object.Limits) {
+	reader := bufio.NewReader(in)
+	builtins := evaluator.NewBuiltinRegistryWithStreams(&object.Streams{Stdin: reader, Stdout: out, Stderr: out})
+	environment := object.NewEnvironmentWithBuiltins(builtins)
+	environment.SetLimits(limits)
+	macros := object.NewEnvironmentWithBuiltins(builtins)
+	macros.SetLimits(limits)
+
 	repl := Repl{
 		Mode:        EVAL,
 		out:         out,
-		environment: object.NewEnvironment(),
+		environment: environment,
+		macros:      macros,
+		limits:      limits,
+		builtins:    builtins,
+		symbolTable: compiler.NewWithBuiltins(builtins).SymbolTable(),
+		constants:   []object.Object{},
+		globals:     vm.NewGlobalsStore(),
 	}
 
 	repl.OutputUsage()
 
 	for {
 		io.WriteString(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
 		if line == "exit" || line == "exit()" {
 			fmt.Fprintln(out, "Exiting...")
 			break;
 		}
 
 		if repl.Configure(line) {
-			fmt.Fprintln(out, "Successfully configured\n")
+			fmt.Fprint(out, "Successfully configured\n\n")
 			continue
 		}
 
 		repl.Handle(line)
+		if repl.exited {
+			fmt.Fprintln(out, "Exiting...")
+			break
+		}
 		fmt.Fprintln(out)
 	}
 }
@@ -0,0 +1,45 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/alanfoster/monkey/object"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	input := strings.Join([]string{
+		`let greet = fn(name) { puts("hello " + name) }`,
+		`greet("eval")`,
+		VM_MODE,
+		`let greet = fn(name) { puts("hello " + name) }`,
+		`greet("vm")`,
+		"exit",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, &object.Limits{})
+
+	assert.Contains(t, out.String(), "This is the monkey programming language!")
+	assert.Contains(t, out.String(), ">> hello eval\nnull\n")
+	assert.Contains(t, out.String(), ">> hello vm\nnull\n")
+	assert.Contains(t, out.String(), "Exiting...")
+}
+
+func TestStartReadsEachLine(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let a = 1;\r\nlet b = a + 1;\nb * 10"), &out, &object.Limits{})
+
+	assert.Contains(t, out.String(), ">> 20\n")
+	assert.NotContains(t, out.String(), "Error")
+}
+
+func TestStartStopsOnExitBuiltin(t *testing.T) {
+	for _, mode := range []string{EVAL_MODE, VM_MODE} {
+		var out bytes.Buffer
+		Start(strings.NewReader(mode+"\nexit(3)\nputs(1)\n"), &out, &object.Limits{})
+
+		assert.True(t, strings.HasSuffix(out.String(), ">> Exiting...\n"), out.String())
+	}
+}
@@ -13,11 +13,11 @@ expect -f -<<EOF
     send "mode=lex\n"
     expect "Entering lex mode"
     send "1 + 2 + 3\n"
-    expect "{Type:INT Literal:1}"
-    expect "{Type:+ Literal:+}"
-    expect "{Type:INT Literal:2}"
-    expect "{Type:+ Literal:+}"
-    expect "{Type:INT Literal:3}"
+    expect "{Type:INT Literal:1} 1:1"
+    expect "{Type:+ Literal:+} 1:3"
+    expect "{Type:INT Literal:2} 1:5"
+    expect "{Type:+ Literal:+} 1:7"
+    expect "{Type:INT Literal:3} 1:9"
 
     ## Testing Parsing
     send "mode=parse\n"
@@ -27,7 +27,7 @@ expect -f -<<EOF
 
     ## Testing Parsing Failures
     send "let foo \n"
-    expect "expected next token to be =, but got {EOF } instead"
+    expect "1:9: expected '='"
 
     ## Testing Evaluation
     send "mode=eval\n"
@@ -37,7 +37,7 @@ expect -f -<<EOF
 
     ## Testing Parsing Failures
     send "let foo \n"
-    expect "expected next token to be =, but got {EOF } instead"
+    expect "1:9: expected '='"
 
     ## Exiting
     send "exit\n"
@@ -1,10 +1,81 @@
 package token
 
+import (
+	"fmt"
+	"strings"
+)
+
 type TokenType string
 
+// A location within the source input
+type Position struct {
+	Filename string // Optional, empty when the input did not come from a file
+	Offset   int    // Byte offset, starting at 0
+	Line     int    // Line number, starting at 1
+	Column   int    // Column number in characters, starting at 1
+}
+
+// A position is only valid if it was produced by the lexer
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Returns the position in the form of `file:line:column`, or `line:column` when there is no filename
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Renders a message along with its position, the line of source it refers to, and a caret pointing
+// at the column, i.e.
+//
+//	file.monkey:12:7: expected ')'
+//	puts(1, 2;
+//	         ^
+func FormatError(pos Position, message string, source string) string {
+	if !pos.IsValid() {
+		return message
+	}
+
+	header := pos.String() + ": " + message
+
+	lines := strings.Split(source, "\n")
+	if pos.Line > len(lines) {
+		return header
+	}
+
+	line := []rune(strings.TrimRight(lines[pos.Line-1], "\r"))
+	column := pos.Column - 1
+	if column > len(line) {
+		column = len(line)
+	}
+
+	// Tabs are kept so that the caret lines up with the source line
+	var caret strings.Builder
+	for _, ch := range line[:column] {
+		if ch == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+
+	return header + "\n" + string(line) + "\n" + caret.String()
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // The position of the first character of the token
+	End     Position // The position immediately after the last character of the token
 }
 
 const (
@@ -14,6 +85,8 @@ const (
 	// Identifiers + Literal
 	IDENTIFIER = "IDENTIFIER" // add, foobar, x, y
 	INT        = "INT"        // 12345...
+	FLOAT      = "FLOAT"      // 3.14, 1e9...
+	BIGINT     = "BIGINT"     // 12345n...
 	STRING     = "STRING"
 
 	// Operators
@@ -25,13 +98,34 @@ const (
 	NOT_EQ   = "!="
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	PLUS_EQ     = "+="
+	MINUS_EQ    = "-="
+	ASTERISK_EQ = "*="
+	SLASH_EQ    = "/="
 
-	LESS_THAN    = "<"
-	GREATER_THAN = ">"
+	LESS_THAN       = "<"
+	GREATER_THAN    = ">"
+	LESS_THAN_EQ    = "<="
+	GREATER_THAN_EQ = ">="
+
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	AND = "&&"
+	OR  = "||"
 
 	// Deliminators
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
 	LEFT_PAREN  = "("
 	RIGHT_PAREN = ")"
@@ -48,16 +142,64 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
+	MACRO    = "MACRO"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"macro":    MACRO,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+}
+
+// Describes a token type for use within error messages, i.e. `')'`, `'let'` or `identifier`
+func Describe(t TokenType) string {
+	switch t {
+	case IDENTIFIER:
+		return "identifier"
+	case INT:
+		return "integer"
+	case FLOAT:
+		return "float"
+	case BIGINT:
+		return "big integer"
+	case STRING:
+		return "string"
+	case EOF:
+		return "end of input"
+	}
+
+	for keyword, keywordType := range keywords {
+		if keywordType == t {
+			return "'" + keyword + "'"
+		}
+	}
+
+	return "'" + string(t) + "'"
 }
 
 func LookupIdentifier(identifier string) TokenType {
@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"github.com/alanfoster/monkey/code"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/token"
+)
+
+// The execution state of a single function call
+type Frame struct {
+	closure     *object.Closure
+	ip          int // The instruction pointer within the closure's instructions
+	basePointer int // The stack pointer before the call, locals are stored from here onwards
+
+	// Calls in tail position replace the frame. As with the evaluator, only the first function
+	// called and the latest tail call are still known for stack traces
+	firstFunction string
+	tailCalls     int
+	tailCallSite  token.Position
+}
+
+func NewFrame(closure *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		closure:     closure,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+}
+
+func (f *Frame) program() *object.ProgramState {
+	return f.closure.Program
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.closure.Fn.Instructions
+}
@@ -0,0 +1,677 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/alanfoster/monkey/ast"
+	"github.com/alanfoster/monkey/code"
+	"github.com/alanfoster/monkey/compiler"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/object"
+)
+
+const (
+	// The initial sizes of the stack and call frames, which grow as needed. The depth of calls is
+	// bounded by the limits of the vm instead
+	StackSize   = 2048
+	FramesSize  = 64
+	GlobalsSize = 65536
+)
+
+// The vm shares the evaluator's singletons, so that objects behave identically in both engines
+var (
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+	NULL  = evaluator.NULL
+)
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:              "+",
+	code.OpSub:              "-",
+	code.OpMul:              "*",
+	code.OpDiv:              "/",
+	code.OpEqual:            "==",
+	code.OpNotEqual:         "!=",
+	code.OpGreaterThan:      ">",
+	code.OpLessThan:         "<",
+	code.OpGreaterThanEqual: ">=",
+	code.OpLessThanEqual:    "<=",
+	code.OpMod:              "%",
+	code.OpPow:              "**",
+	code.OpBitAnd:           "&",
+	code.OpBitOr:            "|",
+	code.OpBitXor:           "^",
+	code.OpShiftLeft:        "<<",
+	code.OpShiftRight:       ">>",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus:  "-",
+	code.OpBang:   "!",
+	code.OpBitNot: "~",
+}
+
+// An error raised by the monkey program itself, such as a type mismatch
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Object.Message
+}
+
+type VM struct {
+	program  *object.ProgramState
+	builtins []*object.Builtin
+	registry *object.BuiltinRegistry
+	modules  *object.ModuleRegistry
+	limits   *object.Limits
+
+	stack []object.Object
+	sp    int // Always points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// The active try blocks, innermost last
+	handlers []handler
+
+	lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, NewGlobalsStore())
+}
+
+// Creates a vm for bytecode which was compiled with the same registry of builtins
+func NewWithBuiltins(bytecode *compiler.Bytecode, registry *object.BuiltinRegistry) *VM {
+	return NewWithState(bytecode, NewGlobalsStore(), registry)
+}
+
+func NewGlobalsStore() []object.Object {
+	return make([]object.Object, GlobalsSize)
+}
+
+// Creates a vm which shares its globals with previous runs, as used by the REPL
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	return NewWithState(bytecode, globals, evaluator.NewBuiltinRegistry())
+}
+
+// Creates a vm which shares its globals with previous runs, for bytecode which was compiled with
+// the same registry of builtins
+func NewWithState(bytecode *compiler.Bytecode, globals []object.Object, registry *object.BuiltinRegistry) *VM {
+	var builtins []*object.Builtin
+	for _, name := range registry.Names() {
+		builtin, _ := registry.Lookup(name)
+		builtins = append(builtins, builtin)
+	}
+
+	program := &object.ProgramState{
+		Constants:   bytecode.Constants,
+		Globals:     globals,
+		GlobalNames: bytecode.GlobalNames,
+	}
+
+	mainFunction := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	mainClosure := &object.Closure{Fn: mainFunction, Program: program}
+
+	frames := make([]*Frame, FramesSize)
+	frames[0] = NewFrame(mainClosure, 0)
+
+	return &VM{
+		program:  program,
+		builtins: builtins,
+		registry: registry,
+		modules:  object.NewModuleRegistry(),
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// The value of the last expression statement which was executed, i.e. the result of the program
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// Limits the instructions run by the vm. The limits must be started before running
+func (vm *VM) SetLimits(limits *object.Limits) {
+	vm.limits = limits
+}
+
+func (vm *VM) Run() (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &RuntimeError{Object: evaluator.NewInternalError(recovered)}
+		}
+	}()
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		frame := vm.currentFrame()
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		if errorObject := vm.limits.Step(); errorObject != nil {
+			err := positionError(&RuntimeError{Object: errorObject}, frame, ip)
+			vm.recordStack(err, 1)
+			return err
+		}
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.currentFrame().program().Constants[constIndex])
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterThanEqual, code.OpLessThanEqual, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalInfixExpression(infixOperators[op], left, right, vm.limits.ChecksOverflow()))
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			right := vm.pop()
+			err = vm.pushResult(evaluator.EvalPrefixExpression(prefixOperators[op], right, vm.limits.ChecksOverflow()))
+		case code.OpTrue:
+			err = vm.push(TRUE)
+		case code.OpFalse:
+			err = vm.push(FALSE)
+		case code.OpNull:
+			err = vm.push(NULL)
+		case code.OpJump:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = position - 1
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = position - 1
+			}
+		case code.OpIterStart:
+			iterable := vm.stack[vm.sp-1]
+			if iterable.Type() != object.ARRAY {
+				err = vm.newRuntimeError("cannot iterate over %s", iterable.Type())
+			} else {
+				err = vm.push(&object.Integer{Value: 0})
+			}
+		case code.OpIterNext:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.stack[vm.sp-2].(*object.Array)
+			index := vm.stack[vm.sp-1].(*object.Integer).Value
+
+			if index >= int64(len(array.Elements)) {
+				vm.currentFrame().ip = position - 1
+			} else {
+				vm.stack[vm.sp-1] = &object.Integer{Value: index + 1}
+				err = vm.push(array.Elements[index])
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.currentFrame().program().Globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			program := vm.currentFrame().program()
+			value := program.Globals[globalIndex]
+			if value == nil {
+				err = vm.newRuntimeError("identifier not found: %s", program.GlobalNames[globalIndex])
+			} else {
+				err = vm.push(value)
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if value == nil {
+				err = vm.newRuntimeError("identifier not found: %s", vm.localName(int(localIndex)))
+			} else {
+				err = vm.push(value)
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.builtins[builtinIndex])
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.currentFrame().closure.Free[freeIndex])
+		case code.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().closure)
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			program := vm.currentFrame().program()
+			if program.Globals[globalIndex] == nil {
+				err = vm.newRuntimeError("cannot assign to undeclared identifier: %s", program.GlobalNames[globalIndex])
+			} else {
+				program.Globals[globalIndex] = vm.pop()
+			}
+		case code.OpMakeCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := vm.currentFrame().basePointer + int(localIndex)
+			vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.localCell(int(localIndex)).Value
+			if value == nil {
+				err = vm.newRuntimeError("identifier not found: %s", vm.localName(int(localIndex)))
+			} else {
+				err = vm.push(value)
+			}
+		case code.OpSetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.localCell(int(localIndex)).Value = vm.pop()
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			closure := vm.currentFrame().closure
+			value := closure.Free[freeIndex].(*object.Cell).Value
+			if value == nil {
+				err = vm.newRuntimeError("identifier not found: %s", closure.Fn.FreeNames[freeIndex])
+			} else {
+				err = vm.push(value)
+			}
+		case code.OpSetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.currentFrame().closure.Free[freeIndex].(*object.Cell).Value = vm.pop()
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err == nil {
+				vm.sp = vm.sp - numElements
+				err = vm.push(hash)
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndexExpression(left, index))
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndexAssignment(left, index, value))
+		case code.OpDup2:
+			if err = vm.push(vm.stack[vm.sp-2]); err == nil {
+				err = vm.push(vm.stack[vm.sp-2])
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeCall(int(numArgs))
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeTailCall(int(numArgs))
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			path := vm.currentFrame().program().Constants[constIndex].(*object.String).Value
+			err = vm.pushResult(evaluator.ImportModule(vm.modules, path, vm.runModule))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// Returning from the top level ends the program, as with the evaluator
+			if vm.framesIndex == 1 {
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			vm.endReturnedTries()
+			err = vm.push(returnValue)
+		case code.OpQuote:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numValues := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			values := make([]object.Object, numValues)
+			copy(values, vm.stack[vm.sp-numValues:vm.sp])
+			vm.sp = vm.sp - numValues
+
+			quote := vm.currentFrame().program().Constants[constIndex].(*object.Quote)
+			err = vm.pushResult(evaluator.QuoteWithValues(quote.Node, values))
+		case code.OpTry:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			vm.handlers = append(vm.handlers, handler{framesIndex: vm.framesIndex, sp: vm.sp, position: position})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			err = &RuntimeError{Object: evaluator.Throw(vm.pop())}
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if err != nil && !vm.catch(positionError(err, frame, ip)) {
+			vm.recordStack(err, 1)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Positions a runtime error at the source of the instruction which raised it, unless it is already
+// positioned such as when raised within an imported module
+func positionError(err error, frame *Frame, ip int) error {
+	if runtimeError, ok := err.(*RuntimeError); ok && !runtimeError.Object.Pos.IsValid() {
+		runtimeError.Object.Pos = frame.closure.Fn.SourceMap.Lookup(ip)
+	}
+	return err
+}
+
+// A try block which is active, along with the state to restore when it catches an error
+type handler struct {
+	framesIndex int
+	sp          int
+	position    int // The offset of the instructions which handle the caught error
+}
+
+// Passes the error to the innermost active try block, returning false when the error cannot be
+// caught by one
+func (vm *VM) catch(err error) bool {
+	runtimeError, ok := err.(*RuntimeError)
+	if !ok || !runtimeError.Object.Catchable() || len(vm.handlers) == 0 {
+		return false
+	}
+
+	handler := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	// Unwind any calls made within the try block
+	vm.recordStack(err, handler.framesIndex)
+	vm.framesIndex = handler.framesIndex
+	vm.sp = handler.sp
+	vm.currentFrame().ip = handler.position - 1
+
+	return vm.push(&object.Exception{Error: runtimeError.Object}) == nil
+}
+
+// Records the function calls the error propagates through as the frames from the given index
+// onwards are unwound, innermost call first as with the evaluator
+func (vm *VM) recordStack(err error, framesIndex int) {
+	runtimeError, ok := err.(*RuntimeError)
+	if !ok {
+		return
+	}
+
+	for i := vm.framesIndex - 1; i >= framesIndex; i-- {
+		frame, caller := vm.frames[i], vm.frames[i-1]
+		callSite := caller.closure.Fn.SourceMap.Lookup(caller.ip)
+
+		if frame.tailCalls == 0 {
+			runtimeError.Object.Stack = append(runtimeError.Object.Stack, object.StackFrame{
+				Function: frame.closure.Fn.Name,
+				CallSite: callSite,
+			})
+			continue
+		}
+
+		runtimeError.Object.Stack = append(runtimeError.Object.Stack, object.StackFrame{
+			Function: frame.closure.Fn.Name,
+			CallSite: frame.tailCallSite,
+		})
+		if frame.tailCalls > 1 {
+			runtimeError.Object.Stack = append(runtimeError.Object.Stack, object.StackFrame{Omitted: frame.tailCalls - 1})
+		}
+		runtimeError.Object.Stack = append(runtimeError.Object.Stack, object.StackFrame{
+			Function: frame.firstFunction,
+			CallSite: callSite,
+		})
+	}
+}
+
+// Try blocks end when the function they are within returns
+func (vm *VM) endReturnedTries() {
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+}
+
+func (vm *VM) newRuntimeError(format string, a ...interface{}) error {
+	return &RuntimeError{Object: &object.Error{Message: fmt.Sprintf(format, a...)}}
+}
+
+// Pushes the result of an operation, halting the vm if the operation failed
+func (vm *VM) pushResult(result object.Object) error {
+	if errorObject, ok := result.(*object.Error); ok {
+		return &RuntimeError{Object: errorObject}
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) buildHash(startIndex int, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, vm.newRuntimeError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	program := vm.currentFrame().program()
+	function, ok := program.Constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", program.Constants[constIndex])
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free, Program: program})
+}
+
+// Compiles and runs an imported module within its own vm, sharing the modules imported so far
+func (vm *VM) runModule(program *ast.Program) (map[string]object.Object, object.Object) {
+	c := compiler.NewWithBuiltins(vm.registry)
+	if err := c.Compile(program); err != nil {
+		return nil, &object.Error{Message: fmt.Sprintf("compilation failed: %s", err)}
+	}
+
+	bytecode := c.Bytecode()
+	machine := NewWithBuiltins(bytecode, vm.registry)
+	machine.modules = vm.modules
+	machine.limits = vm.limits
+
+	if err := machine.Run(); err != nil {
+		if runtimeError, ok := err.(*RuntimeError); ok {
+			return nil, runtimeError.Object
+		}
+		return nil, &object.Error{Message: err.Error()}
+	}
+
+	exports := make(map[string]object.Object)
+	for index, name := range bytecode.GlobalNames {
+		value := machine.program.Globals[index]
+		if value != nil && evaluator.IsExported(name) {
+			exports[name] = value
+		}
+	}
+
+	return exports, nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return vm.newRuntimeError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(closure *object.Closure, numArgs int) error {
+	if numArgs != closure.Fn.NumParameters {
+		return vm.newRuntimeError("wrong number of arguments. got=%d, want=%d", numArgs, closure.Fn.NumParameters)
+	}
+
+	// The main frame is not a function call, so is excluded from the depth
+	if vm.framesIndex > vm.limits.MaxCallDepth() {
+		return vm.newRuntimeError(object.MaxRecursionDepthReached)
+	}
+
+	// The arguments become the first locals of the new frame
+	frame := NewFrame(closure, vm.sp-numArgs)
+	vm.pushFrame(frame)
+	vm.allocateLocals(frame, numArgs)
+
+	return nil
+}
+
+// Calls a closure in tail position by replacing the current frame, so that the stack does not grow
+// and the call does not count towards the recursion depth. Other calls are made as usual
+func (vm *VM) executeTailCall(numArgs int) error {
+	closure, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || numArgs != closure.Fn.NumParameters {
+		return vm.executeCall(numArgs)
+	}
+
+	frame := vm.currentFrame()
+	if frame.tailCalls == 0 {
+		frame.firstFunction = frame.closure.Fn.Name
+	}
+	frame.tailCalls++
+	frame.tailCallSite = frame.closure.Fn.SourceMap.Lookup(frame.ip)
+
+	// The closure and its arguments take the place of those of the current call
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	frame.closure = closure
+	frame.ip = -1
+	vm.allocateLocals(frame, numArgs)
+
+	return nil
+}
+
+func (vm *VM) allocateLocals(frame *Frame, numArgs int) {
+	vm.sp = frame.basePointer + frame.closure.Fn.NumLocals
+	vm.growStack(vm.sp)
+
+	// Locals other than the arguments are unset until defined, rather than holding whatever an
+	// earlier frame left in their slot
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = NULL
+	}
+
+	return vm.pushResult(result)
+}
+
+func (vm *VM) push(o object.Object) error {
+	vm.growStack(vm.sp + 1)
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// Grows the stack until it has at least the given number of slots
+func (vm *VM) growStack(size int) {
+	for len(vm.stack) < size {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) localName(localIndex int) string {
+	return vm.currentFrame().closure.Fn.LocalNames[localIndex]
+}
+
+func (vm *VM) localCell(localIndex int) *object.Cell {
+	return vm.stack[vm.currentFrame().basePointer+localIndex].(*object.Cell)
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, make([]*Frame, len(vm.frames))...)
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
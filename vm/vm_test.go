@@ -0,0 +1,512 @@
+package vm
+
+import (
+	"context"
+	"testing"
+	"github.com/alanfoster/monkey/compiler"
+	"github.com/alanfoster/monkey/evaluator"
+	"github.com/alanfoster/monkey/lexer"
+	"github.com/alanfoster/monkey/object"
+	"github.com/alanfoster/monkey/parser"
+	"github.com/alanfoster/monkey/token"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"time"
+)
+
+// Runs the input through the vm, returning the inspected result or error
+func run(t *testing.T, input string) string {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	c := compiler.New()
+	if !assert.NoError(t, c.Compile(program), input) {
+		return ""
+	}
+
+	machine := New(c.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	return machine.LastPoppedStackElem().Inspect()
+}
+
+func TestVM(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"-5 * -5 / 2", "12"},
+		{"!true == false", "true"},
+		{`"hello" + " " + "world"`, "hello world"},
+		{"if (1 > 2) { 10 }", "null"},
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{"let a = 5; let b = a * 2; a + b", "15"},
+		{"let a = 5;", "5"},
+		{"[1, 2 * 2, 3][1]", "4"},
+		{`{"a": 1, "b": 2}["b"]`, "2"},
+		{`keys({"a": 1, "b": 2})`, "[a, b]"},
+		{"let add = fn(a, b) { a + b }; add(1, 2)", "3"},
+		{"let early = fn() { return 1; 2 }; early()", "1"},
+		{"fn() { }()", "null"},
+		{"let newAdder = fn(a) { fn(b) { a + b } }; newAdder(2)(3)", "5"},
+		{
+			`
+				let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1) } };
+				let wrapper = fn() { countDown(1); };
+				wrapper();
+			`,
+			"0",
+		},
+		{
+			`
+				let wrapper = fn() {
+					let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1) } };
+					countDown(1);
+				};
+				wrapper();
+			`,
+			"0",
+		},
+		{"return 10; 20", "10"},
+		{"5 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"unknown", "ERROR: identifier not found: unknown"},
+		{"1(2)", "ERROR: not a function: INTEGER"},
+		{"fn(a) { a }()", "ERROR: wrong number of arguments. got=0, want=1"},
+		{"len(1)", "ERROR: argument to `len` not supported, got INTEGER"},
+		{"{[1]: 2}", "ERROR: unusable as hash key: ARRAY"},
+		{"let f = fn() { 1 + f() }; f()", "ERROR: maximum recursion depth exceeded"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, run(t, test.input), test.input)
+	}
+}
+
+func TestGlobalsPersistAcrossRuns(t *testing.T) {
+	symbolTable := compiler.New().SymbolTable()
+	constants := []object.Object{}
+	globals := NewGlobalsStore()
+
+	var result object.Object
+	for _, line := range []string{"let a = 1;", "let b = fn() { a + 1 };", "b()"} {
+		p := parser.New(lexer.New(line))
+		program := p.ParseProgram()
+
+		c := compiler.NewWithState(symbolTable, constants)
+		assert.NoError(t, c.Compile(program))
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsStore(bytecode, globals)
+		assert.NoError(t, machine.Run())
+		result = machine.LastPoppedStackElem()
+	}
+
+	assert.Equal(t, "2", result.Inspect())
+}
+
+// The vm must produce identical results to the evaluator
+func TestMatchesEvaluator(t *testing.T) {
+	inputs := []string{
+		"1 + 2 + 3",
+		"5 / 2",
+		"1 == true",
+		"true == true",
+		"[1, 2] == [1, 2]",
+		"-true",
+		`"a" - "b"`,
+		`!"a"`,
+		"[1, 2, 3][3]",
+		"[1, 2, 3][-1]",
+		`{"a": 1}["b"]`,
+		`{"a": 1}[fn() {}]`,
+		"if (null) { 1 }",
+		"let a = 1; let a = a + 1; a",
+		"let f = fn(x) { x }; f",
+		`let reduce = fn(array, initial, reducer) {
+			let iter = fn(array, acc) {
+				if (len(array) == 0) { acc } else { iter(rest(array), reducer(acc, first(array))) }
+			};
+			iter(array, initial);
+		};
+		let map = fn(array, f) { reduce(array, [], fn(acc, next) { push(acc, f(next)) }) };
+		map([1, 2, 3], fn(x) { x * 2 })`,
+		"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(10)",
+		`let h = {"a": 1, "b": 2}; delete(h, "a")`,
+		"fn() { let x = 5 }()",
+		"if (true) { }",
+		"first([])",
+		"while (false) { }",
+		"let i = 0; while (i < 5) { let i = i + 1; if (i == 3) { break; } }; i",
+		"let odds = []; let i = 0; while (i < 6) { let i = i + 1; if (i / 2 * 2 == i) { continue; } let odds = push(odds, i); }; odds",
+		"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let sum = sum + x; }; sum",
+		"let f = fn(xs) { let total = 0; for (x in xs) { for (y in xs) { let total = total + x * y; } }; total }; f([1, 2, 3])",
+		"let find = fn(xs) { for (x in xs) { if (x > 1) { return x; } }; -1 }; find([1, 5, 7])",
+		"let f = fn(xs) { let total = 0; for (x in xs) { if (x > 1) { total = total + x } }; let g = fn() { total }; g() }; f([1, 2, 3])",
+		"let f = fn(n) { let i = 0; while (i < n) { i = i + 1 }; try { throw i } catch (e) { let g = fn() { e.payload + i } g() } }; f(3)",
+		"fn() { while (false) { } }()",
+		"for (x in [1, 2]) { }; x",
+		"for (x in 5) { }",
+		"let x = 1; x += 2; x *= 3",
+		"x = 1",
+		"let f = fn() { y = 1 }; f()",
+		"let count = 0; let increment = fn() { count += 1 }; increment(); increment(); count",
+		"let counter = fn() { let count = 0; fn() { count += 1 } }; let c = counter(); c(); c(); c()",
+		"let counter = fn(count) { [fn() { count += 1 }, fn() { count }] }; let c = counter(5); c[0](); c[0](); c[1]()",
+		"let f = fn() { let x = 1; let g = fn() { fn() { x = x * 10 } }; g()(); g()(); x }; f()",
+		"let f = fn() { let fns = []; for (x in [1, 2, 3]) { fns = push(fns, fn() { x }) }; fns[0]() }; f()",
+		"let f = fn() { f = 5 }; f(); f",
+		"let xs = [1, 2, 3]; xs[1] = 5; xs",
+		"let grid = [[1, 2], [3, 4]]; grid[1][0] *= 10; grid",
+		`let h = {"a": 1}; h["b"] = 2; h["a"] -= 1; h`,
+		"let xs = [1]; xs[1] = 2",
+		"let i = 0; while (i < 5) { i += 1 }; i",
+		`let s = "a"; s += "b"; s`,
+		"true && 1",
+		"if (false) { 1 } && true",
+		"0 || false",
+		"false || if (false) { 1 }",
+		"false && undefined",
+		"true || undefined",
+		"true && undefined",
+		"let calls = 0; let f = fn() { calls += 1; true }; false && f(); true || f(); f() && f(); calls",
+		"let i = 0; while (i < 10 && i != 4) { i += 1 }; i",
+		"1.5 + 2",
+		"7 / 2.0",
+		"-0.5 * 3",
+		"1 == 1.0",
+		"2.5 > 3",
+		"1.5 + true",
+		"let x = 1; x /= 4.0; x",
+		"int(3.7) + float(2)",
+		`let math = import "../evaluator/testdata/modules/math.monkey"; math.area(2) + math["pi"]`,
+		`let lib = import "../evaluator/testdata/modules/nested/uses_parent.monkey"; lib.double_area(1)`,
+		`let a = import "../evaluator/testdata/modules/counter.monkey"; let b = import "../evaluator/testdata/modules/counter.monkey"; a.increment(); b.increment(); a.current()`,
+		`let math = import "../evaluator/testdata/modules/math.monkey"; math._square`,
+		`import "../evaluator/testdata/modules/cycle_a.monkey"`,
+		`import "../evaluator/testdata/modules/failing.monkey"`,
+		`let h = {"name": "monkey"}; h.name`,
+		`let lib = import "../evaluator/testdata/modules/macros.monkey"; [lib.sign(1), lib.sign(-1)]`,
+		"quote(1 + 2)",
+		"quote(fn(x) { x * 2 })",
+		"quote(unquote(4 + 4))",
+		"let x = 8; quote(x + unquote(x))",
+		"let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))",
+		"let f = fn(n) { quote(unquote(n)) }; f(1); f(2)",
+		"quote(unquote([1]))",
+		"let quote = fn(x) { x * 2 }; quote(21)",
+		"let f = fn(quote) { quote(21) }; f(fn(x) { x * 2 })",
+		"let f = fn() { 1 + f() }; f()",
+		"let f = fn() { exit(3); 1 }; f()",
+		`exit("3")`,
+		"10 / 0",
+		"let f = fn(a) { if (a) { let y = 1; }; y }; f(false)",
+		"let f = fn(a) { if (a) { let y = 1; }; y }; f(true); f(false)",
+		"let f = fn(a) { if (a) { let y = 1; let g = fn() { y } }; y }; f(true); f(false)",
+		"let f = fn() { let g = fn() { y }; let r = g(); let y = 2; r }; f()",
+		"let outer = fn() { let g = fn() { y }; let y = 2; g() }; outer()",
+		"let outer = fn() { let g = fn() { fn() { y + 1 } }; let y = 2; g()() }; outer()",
+		"let y = 1; let outer = fn() { let a = y; let y = 2; [a, y] }; outer()",
+		"let isEven = fn(n) { let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(n) }; [isEven(10), isEven(7)]",
+		"let out = []; for (x in [1, 2, 3]) { let v = 10 + if (x == 2) { let i = 0; while (true) { i += 1; if (i == 3) { break } }; i } else { 0 }; out = push(out, v) }; out",
+		"let out = []; for (x in [1, 2, 3]) { if (x == 2) { if (true) { continue } }; out = push(out, x) }; out",
+		"[2 <= 2, 3 >= 4, -7 % 3, 7 % 0, 7.5 % 2]",
+		"[2 ** 3 ** 2, -2 ** 2, 2 ** 64, 2 ** -1]",
+		"[12 & 10, 12 | 10, 12 ^ 10, ~0, 1 << 63, -16 >> 2, ~5n]",
+		`let crc = 0; for (b in [1, 2, 3]) { crc = ((crc << 5) ^ (crc >> 27) ^ b) & 4294967295 }; crc`,
+		`[[1, "a"], {"b": [2]}] == [[1.0, "a"], {"b": [2n]}]`,
+		`["a" < "b", "b" > "abc", [1, 2] > [1], [1, 2] != [1, 2]]`,
+		`[1] < ["a"]`,
+		"123456789012345678901234567890n * 3 - 1",
+		"9223372036854775807 + 1 > 9223372036854775807",
+		"let x = -9223372036854775807 - 1; [-x, x / -1, 2n / 0]",
+		"10n * 0.5 == 5",
+		"-9223372036854775808",
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(2000)",
+		`let h = {10n: "a"}; let xs = [1, 2]; xs[1n] = h[5 + 5]; xs`,
+		"let x = 10; x /= 0",
+		"try { 1 / 0 } catch (e) { e.message }",
+		"try { 1 + true } catch (e) { e.message }",
+		"try { x } catch (e) { e.payload }",
+		`try { throw "bad" } catch (e) { e.message }`,
+		`try { throw {"code": 1} } catch (e) { [e.message, e.payload["code"]] }`,
+		"try { 1 } catch (e) { 2 }",
+		"let x = try { throw 1 } catch (e) { e.payload + 1 }; x",
+		"try { throw 1 } catch (e) { e }",
+		"try { throw 1 } catch (e) { e.missing }",
+		"try { 5 } finally { 6 }",
+		"let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log",
+		`let log = []; let f = fn() { try { throw "x" } finally { log = push(log, "finally") } }; try { f() } catch (e) { log = push(log, e.message) }; log`,
+		"let log = []; let f = fn() { try { return 1 } finally { log = push(log, 2) } }; [f(), log]",
+		"let f = fn() { try { return 1 } finally { return 2 } }; f()",
+		"let f = fn() { try { throw 1 } finally { return 2 } }; f()",
+		`let log = []; for (x in [1, 2, 3]) { try { if (x == 2) { break } log = push(log, x) } finally { log = push(log, "f") } }; log`,
+		`let log = []; for (x in [1, 2, 3]) { try { if (x == 2) { continue } log = push(log, x) } finally { log = push(log, "f") } }; log`,
+		`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e.message }`,
+		`let log = []; try { try { throw 1 } catch (e) { throw 2 } finally { log = push(log, "f") } } catch (e) { push(log, e.payload) }`,
+		`throw "oops"`,
+		"let f = fn() { 1 + true }; let g = fn() { f() + 1 }; try { g() } catch (e) { e.message }",
+		"try { exit(1) } catch (e) { 2 }",
+		"let f = fn() { 1 + f() }; try { f() } catch (e) { e.message }",
+		"let f = fn() { let i = 0; while (true) { try { i += 1; if (i == 3) { return i } } catch (e) { } } }; f()",
+		`let f = fn(x) { if (x > 2) { throw "too big" } x }; let total = 0; for (x in [1, 2, 3, 4]) { try { total += f(x) } catch (e) { total += 100 } }; total`,
+		`let f = fn() { let g = fn() { throw "x" }; try { g() } catch (e) { return e.message } }; f()`,
+		"try { throw 1 } catch (e) { e }; e.payload",
+		`let e = 5; try { throw "x" } catch (e) { 1 }; e`,
+		`let f = fn() { throw "x" }; let g = fn() { try { f() } catch (e) { e.stack } }; g()`,
+		`let f = fn() { [1][true] }; let g = fn() { f() }; try { [g()] } catch (e) { [e.message, e.stack] }`,
+		"let f = fn() { let e = 5; let r = try { throw 1 } catch (e) { e.payload }; [r, e] }; f()",
+		"let e = 0; let g = 0; try { throw 1 } catch (e) { g = fn() { e.payload } }; [g(), e]",
+		"let f = fn() { let g = 0; try { throw 1 } catch (e) { g = fn() { e.payload } }; let e = 2; [g(), e] }; f()",
+		"try { throw 1 } catch (e) { let y = e.payload + 1 }; y",
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+
+		expected := evaluator.Eval(program, object.NewEnvironment())
+		assert.Equal(t, expected.Inspect(), run(t, input), input)
+	}
+}
+
+func TestErrorLocationsMatchEvaluator(t *testing.T) {
+	inputs := []string{
+		"1 + true",
+		"let x = 1;\nlet y = x + \"a\";",
+		"if (true) {\n  -\"a\"\n}",
+		"let f = fn() {\n  [1, 2][\"a\"]\n};\nf()",
+		"let f = fn(x) { x };\nf(1, 2)",
+		"missing + 1",
+		"let f = fn(n) { 1 + f(n + 1) };\nf(0)",
+		`let f = fn() { throw "x" }; f()`,
+		`try { 1 + true } catch (e) { throw e }`,
+		"let xs = [1];\nxs[3] = 1",
+		"for (x in 5) { x }",
+		"let f = fn() { 1 + true };\nlet g = fn() { f() + 1 };\ng()",
+		"let g = fn() { let h = fn() { missing }; [h()] };\n1 + g()",
+		`let f = fn() { throw "x" }; let g = fn() { try { f() } catch (e) { e.stack } }; throw g()`,
+		"let fail = fn(n) { n + true };\nlet count = fn(n) { if (n == 0) { fail(n) } else { count(n - 1) } };\ncount(3)",
+		"let fail = fn(n) { n + true };\nlet count = fn(n) { if (n == 0) { fail(n) } else { count(n - 1) } };\nlet f = fn() { count(1) + 1 };\nf()",
+		"let f = fn(n) { if (n == 0) { throw n } else { f(n - 1) } };\nlet g = fn() { try { f(5) } catch (e) { e.stack } };\nthrow g()",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		expected, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error)
+		if !assert.True(t, ok, input) {
+			continue
+		}
+
+		c := compiler.New()
+		assert.NoError(t, c.Compile(program))
+
+		runtimeError, ok := New(c.Bytecode()).Run().(*RuntimeError)
+		if assert.True(t, ok, input) {
+			assert.Equal(t, expected.Pos, runtimeError.Object.Pos, input)
+			assert.Equal(t, expected.Stack, runtimeError.Object.Stack, input)
+		}
+	}
+}
+
+func TestRegisteredBuiltins(t *testing.T) {
+	registry := evaluator.NewBuiltinRegistry()
+	registry.Register("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	program := parser.New(lexer.New("double(len([1, 2]))")).ParseProgram()
+	c := compiler.NewWithBuiltins(registry)
+	assert.NoError(t, c.Compile(program))
+
+	machine := NewWithBuiltins(c.Bytecode(), registry)
+	assert.NoError(t, machine.Run())
+	assert.Equal(t, "4", machine.LastPoppedStackElem().Inspect())
+
+	environment := object.NewEnvironmentWithBuiltins(registry)
+	assert.Equal(t, "4", evaluator.Eval(program, environment).Inspect())
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	program := parser.New(lexer.New("let x = 9223372036854775807; [x + 1, x * -1 - 2]")).ParseProgram()
+
+	tests := []struct {
+		limits   *object.Limits
+		expected string
+	}{
+		{&object.Limits{}, "[9223372036854775808n, -9223372036854775809n]"},
+		{&object.Limits{CheckOverflow: true}, "ERROR: integer overflow: 9223372036854775807 + 1"},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New()
+		assert.NoError(t, c.Compile(program))
+
+		machine := New(c.Bytecode())
+		machine.SetLimits(tt.limits)
+		if err := machine.Run(); err != nil {
+			assert.Equal(t, tt.expected, "ERROR: "+err.Error())
+		} else {
+			assert.Equal(t, tt.expected, machine.LastPoppedStackElem().Inspect())
+		}
+	}
+}
+
+func TestInternalErrorsAreRecovered(t *testing.T) {
+	registry := evaluator.NewBuiltinRegistry()
+	registry.Register("fail", func(args ...object.Object) object.Object {
+		panic("unexpected failure")
+	})
+
+	c := compiler.NewWithBuiltins(registry)
+	assert.NoError(t, c.Compile(parser.New(lexer.New("1 + fail()")).ParseProgram()))
+
+	machine := NewWithBuiltins(c.Bytecode(), registry)
+	assert.EqualError(t, machine.Run(), "internal error: unexpected failure")
+}
+
+// Operands have a fixed width, so values which do not fit are compile errors rather than truncated
+func TestOperandsWhichDoNotFitAreCompileErrors(t *testing.T) {
+	elements := strings.Repeat("true, ", 69999) + "true"
+	arguments := strings.Repeat("1, ", 299) + "1"
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"puts(len([" + elements + "]))", "operand of OpArray out of range. got=70000, want at most 65535"},
+		{"fn() { 1 }(" + arguments + ")", "operand of OpCall out of range. got=300, want at most 255"},
+	}
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors())
+
+		assert.EqualError(t, compiler.New().Compile(program), test.expected)
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxDepth int
+		expected string
+	}{
+		{"f(10)", 11, "10"},
+		{"f(10)", 10, "ERROR: maximum recursion depth exceeded"},
+		{"f(20000)", 20001, "20000"},
+		{"f(20000)", 20000, "ERROR: maximum recursion depth exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; " + tt.input)).ParseProgram()
+		c := compiler.New()
+		assert.NoError(t, c.Compile(program))
+
+		machine := New(c.Bytecode())
+		machine.SetLimits(&object.Limits{MaxDepth: tt.maxDepth})
+		if err := machine.Run(); err != nil {
+			assert.Equal(t, tt.expected, "ERROR: "+err.Error())
+		} else {
+			assert.Equal(t, tt.expected, machine.LastPoppedStackElem().Inspect())
+		}
+	}
+}
+
+// As with the evaluator, calls in tail position do not grow the stack, so they are not limited by
+// the maximum depth
+func TestTailCalls(t *testing.T) {
+	elements := "[" + strings.Repeat("1, ", object.DefaultMaxDepth+999) + "1]"
+
+	inputs := []string{
+		`let reduce = fn(array, initial, reducer) {
+			let iter = fn(array, acc) {
+				if (len(array) == 0) { acc } else { iter(rest(array), reducer(acc, first(array))) }
+			};
+			iter(array, initial);
+		};
+		reduce(` + elements + `, 0, fn(acc, next) { acc + next })`,
+		"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(100000)",
+		"let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); }; count(100000)",
+		"let count = fn(n, acc) { if (n > 0) { return count(n - 1, acc + 1); } acc }; count(100000, 0)",
+		"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100000)",
+		"let count = fn(n) { if (n == 0) { 0 } else { let next = n - 1; count(next) } }; count(100000)",
+		"let count = fn(n) { if (n == 0) { 0 } else { let f = fn() { n }; count(n - 1) + f() } }; count(100)",
+		"let f = fn(n) { if (n == 0) { len } else { f(n - 1) } }; f(10)([1])",
+		"let f = fn(n) { if (n == 0) { len(n) } else { f(n - 1) } }; f(10)",
+		"let f = fn(n) { if (n == 0) { f(1, 2) } else { f(n - 1) } }; f(10)",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		expected := evaluator.Eval(program, object.NewEnvironment())
+		assert.Equal(t, expected.Inspect(), run(t, input), input)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn() { while (true) { } }; f()")).ParseProgram()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		limits   *object.Limits
+		ctx      context.Context
+		expected string
+	}{
+		{&object.Limits{MaxSteps: 1000}, context.Background(), "execution limit exceeded"},
+		{&object.Limits{Timeout: time.Millisecond}, context.Background(), "execution limit exceeded"},
+		{&object.Limits{}, cancelled, "cancelled"},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New()
+		assert.NoError(t, c.Compile(program))
+
+		machine := New(c.Bytecode())
+		machine.SetLimits(tt.limits)
+		tt.limits.Start(tt.ctx)
+
+		err := machine.Run()
+		if assert.IsType(t, &RuntimeError{}, err) {
+			assert.Equal(t, tt.expected, err.(*RuntimeError).Object.Message)
+			assert.True(t, err.(*RuntimeError).Object.Interrupted)
+		}
+	}
+}
+
+// Limits are counted differently by the vm, but it stops within the loop as the evaluator does,
+// rather than at the loop statement
+func TestExecutionLimitLocationsMatchEvaluator(t *testing.T) {
+	for _, input := range []string{"while (true) {}", "while (true) { 1 }", "for (x in [1, 2, 3]) { while (x) {} }"} {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		positions := map[token.Position]bool{}
+		for steps := 100; steps < 110; steps++ {
+			environment := object.NewEnvironment()
+			environment.SetLimits(&object.Limits{MaxSteps: int64(steps)})
+			if errorObject, ok := evaluator.Eval(program, environment).(*object.Error); assert.True(t, ok, input) {
+				positions[errorObject.Pos] = true
+			}
+		}
+
+		for steps := 100; steps < 110; steps++ {
+			c := compiler.New()
+			assert.NoError(t, c.Compile(program))
+
+			machine := New(c.Bytecode())
+			machine.SetLimits(&object.Limits{MaxSteps: int64(steps)})
+			if runtimeError, ok := machine.Run().(*RuntimeError); assert.True(t, ok, input) {
+				assert.True(t, positions[runtimeError.Object.Pos], "%s: %s", input, runtimeError.Object.Pos)
+			}
+		}
+	}
+}